@@ -0,0 +1,93 @@
+package httpmocker
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// AssertAllCalled : fails the test if any registered response was never served
+func (server *Server) AssertAllCalled(t testing.TB) {
+	t.Helper()
+
+	server.responsesMu.RLock()
+	keys := map[string]bool{}
+	for _, m := range server.Responses {
+		for _, resps := range m {
+			for _, resp := range resps {
+				keys[callKey(resp.Method, resp.Path)] = true
+			}
+		}
+	}
+	server.responsesMu.RUnlock()
+
+	server.mu.Lock()
+	var uncalled []string
+	for key := range keys {
+		if server.callCounts[key] == 0 {
+			uncalled = append(uncalled, key)
+		}
+	}
+	server.mu.Unlock()
+
+	if len(uncalled) > 0 {
+		sort.Strings(uncalled)
+		t.Errorf("httpmocker: %d mock responses were never called : %s", len(uncalled), strings.Join(uncalled, ", "))
+	}
+}
+
+// AssertNoUnmatched : fails the test if any request did not match registered responses
+func (server *Server) AssertNoUnmatched(t testing.TB) {
+	t.Helper()
+
+	server.mu.Lock()
+	unmatched := append([]string(nil), server.unmatched...)
+	server.mu.Unlock()
+
+	if len(unmatched) > 0 {
+		t.Errorf("httpmocker: %d requests did not match any mock responses : %s", len(unmatched), strings.Join(unmatched, ", "))
+	}
+}
+
+// AssertNotCalled : fails the test if any request with given method and path was received
+func (server *Server) AssertNotCalled(t testing.TB, method, path string) {
+	t.Helper()
+
+	server.mu.Lock()
+	n := 0
+	for _, req := range server.requests {
+		if strings.EqualFold(req.Method, method) && req.Path == path {
+			n++
+		}
+	}
+	server.mu.Unlock()
+
+	if n > 0 {
+		t.Errorf("httpmocker: %s %s should not be called : called %d times", strings.ToUpper(method), path, n)
+	}
+}
+
+// AssertHeaderReceived : fails the test if no request with given method and path carried given header value
+func (server *Server) AssertHeaderReceived(t testing.TB, method, path, header, value string) {
+	t.Helper()
+
+	server.mu.Lock()
+	n := 0
+	found := false
+	for _, req := range server.requests {
+		if !strings.EqualFold(req.Method, method) || req.Path != path {
+			continue
+		}
+		n++
+		if containsString(req.Header[http.CanonicalHeaderKey(header)], value) {
+			found = true
+			break
+		}
+	}
+	server.mu.Unlock()
+
+	if !found {
+		t.Errorf("httpmocker: none of %d requests to %s %s carried %s: %s", n, strings.ToUpper(method), path, header, value)
+	}
+}
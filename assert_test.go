@@ -0,0 +1,126 @@
+package httpmocker
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// recordingT : testing.TB which records failures instead of failing the test
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertions(t *testing.T) {
+	t.Run("all called", func(t *testing.T) {
+		server := Launch().
+			Add("GET", "/hello", http.StatusOK, "hello, world").
+			Add("POST", "/sushi", http.StatusCreated, "🍣")
+		server.Logger = t
+		defer server.Close()
+
+		resp, err := http.Get(fmt.Sprintf("%s/hello", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		resp.Body.Close()
+
+		rt := &recordingT{TB: t}
+		server.AssertAllCalled(rt)
+		if len(rt.errors) != 1 || !strings.Contains(rt.errors[0], "POST /sushi") {
+			t.Errorf("AssertAllCalled should report POST /sushi : actual %v", rt.errors)
+		}
+
+		resp, err = http.Post(fmt.Sprintf("%s/sushi", server.URL), "text/plain", nil)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		resp.Body.Close()
+
+		server.AssertAllCalled(t)
+	})
+
+	t.Run("no unmatched", func(t *testing.T) {
+		server := Launch().Add("GET", "/hello", http.StatusOK, "hello, world")
+		server.Logger = t
+		defer server.Close()
+
+		resp, err := http.Get(fmt.Sprintf("%s/hello", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		resp.Body.Close()
+
+		server.AssertNoUnmatched(t)
+
+		resp, err = http.Get(fmt.Sprintf("%s/unknown", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		resp.Body.Close()
+
+		rt := &recordingT{TB: t}
+		server.AssertNoUnmatched(rt)
+		if len(rt.errors) != 1 || !strings.Contains(rt.errors[0], "GET /unknown") {
+			t.Errorf("AssertNoUnmatched should report GET /unknown : actual %v", rt.errors)
+		}
+	})
+
+	t.Run("not called", func(t *testing.T) {
+		server := Launch().
+			Add("GET", "/hello", http.StatusOK, "hello, world").
+			Add("POST", "/sushi", http.StatusCreated, "🍣")
+		server.Logger = t
+		defer server.Close()
+
+		resp, err := http.Get(fmt.Sprintf("%s/hello", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		resp.Body.Close()
+
+		server.AssertNotCalled(t, "POST", "/sushi")
+		server.AssertNotCalled(t, "GET", "/unknown")
+
+		rt := &recordingT{TB: t}
+		server.AssertNotCalled(rt, "get", "/hello")
+		if len(rt.errors) != 1 || !strings.Contains(rt.errors[0], "GET /hello") {
+			t.Errorf("AssertNotCalled should report GET /hello : actual %v", rt.errors)
+		}
+	})
+
+	t.Run("header received", func(t *testing.T) {
+		server := Launch().Add("GET", "/hello", http.StatusOK, "hello, world")
+		server.Logger = t
+		defer server.Close()
+
+		req, err := http.NewRequest("GET", fmt.Sprintf("%s/hello", server.URL), nil)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		req.Header.Set("Authorization", "Bearer token")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		resp.Body.Close()
+
+		server.AssertHeaderReceived(t, "GET", "/hello", "authorization", "Bearer token")
+
+		rt := &recordingT{TB: t}
+		server.AssertHeaderReceived(rt, "GET", "/hello", "Authorization", "Bearer other")
+		server.AssertHeaderReceived(rt, "POST", "/hello", "Authorization", "Bearer token")
+		if len(rt.errors) != 2 || !strings.Contains(rt.errors[0], "GET /hello carried Authorization: Bearer other") {
+			t.Errorf("AssertHeaderReceived should report missing headers : actual %v", rt.errors)
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package httpmocker
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ResponseBuilder : builds Response fluently
+//
+//	server := httpmocker.Launch(
+//		httpmocker.NewResponse("GET", "/hello").Code(200).JSON(obj).Header("X-Foo", "bar").Build(),
+//	)
+type ResponseBuilder struct {
+	resp Response
+}
+
+// NewResponse : returns builder of mock response for given method and path
+func NewResponse(method, path string) *ResponseBuilder {
+	return &ResponseBuilder{resp: Response{Method: method, Path: path}}
+}
+
+// Code : sets status code, which is Response.Code
+func (b *ResponseBuilder) Code(code int) *ResponseBuilder {
+	b.resp.Code = code
+	return b
+}
+
+// StatusText : sets custom status line such as "200 Custom OK", which is Response.Status
+func (b *ResponseBuilder) StatusText(status string) *ResponseBuilder {
+	b.resp.Status = status
+	return b
+}
+
+// Body : sets response body
+func (b *ResponseBuilder) Body(body string) *ResponseBuilder {
+	b.resp.Body = body
+	return b
+}
+
+// JSON : sets value marshaled as JSON response body
+func (b *ResponseBuilder) JSON(v interface{}) *ResponseBuilder {
+	b.resp.JSONBody = v
+	return b
+}
+
+// ContentType : sets Content-Type of response
+func (b *ResponseBuilder) ContentType(contentType string) *ResponseBuilder {
+	b.resp.ContentType = contentType
+	return b
+}
+
+// Header : adds response header
+func (b *ResponseBuilder) Header(key, value string) *ResponseBuilder {
+	if b.resp.Headers == nil {
+		b.resp.Headers = http.Header{}
+	}
+	b.resp.Headers.Add(key, value)
+	return b
+}
+
+// Query : sets exact query string required for this response to match
+func (b *ResponseBuilder) Query(query string) *ResponseBuilder {
+	b.resp.Query = query
+	return b
+}
+
+// MatchQuery : adds query parameter required for this response to match
+func (b *ResponseBuilder) MatchQuery(key, value string) *ResponseBuilder {
+	if b.resp.MatchQuery == nil {
+		b.resp.MatchQuery = url.Values{}
+	}
+	b.resp.MatchQuery.Add(key, value)
+	return b
+}
+
+// MatchHeader : adds request header required for this response to match
+func (b *ResponseBuilder) MatchHeader(key, value string) *ResponseBuilder {
+	if b.resp.MatchHeaders == nil {
+		b.resp.MatchHeaders = http.Header{}
+	}
+	b.resp.MatchHeaders.Add(key, value)
+	return b
+}
+
+// Delay : sets duration to wait before responding
+func (b *ResponseBuilder) Delay(d time.Duration) *ResponseBuilder {
+	b.resp.Delay = d
+	return b
+}
+
+// Handler : sets handler which the response is delegated to
+func (b *ResponseBuilder) Handler(handler http.HandlerFunc) *ResponseBuilder {
+	b.resp.Handler = handler
+	return b
+}
+
+// Build : returns the built response. The builder can be used to build other responses afterwards.
+func (b *ResponseBuilder) Build() Response {
+	// copy maps so that responses built from the same builder do not share them
+	resp := b.resp
+	if b.resp.Headers != nil {
+		resp.Headers = cloneHeader(b.resp.Headers)
+	}
+	if b.resp.MatchHeaders != nil {
+		resp.MatchHeaders = cloneHeader(b.resp.MatchHeaders)
+	}
+	if b.resp.MatchQuery != nil {
+		resp.MatchQuery = url.Values(cloneHeader(http.Header(b.resp.MatchQuery)))
+	}
+	return resp
+}
@@ -0,0 +1,77 @@
+package httpmocker
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestResponseBuilder(t *testing.T) {
+	server := Launch(
+		NewResponse("GET", "/hello").
+			Code(http.StatusOK).
+			JSON(map[string]string{"message": "hello, world"}).
+			Header("X-Foo", "bar").
+			Delay(10*time.Millisecond).
+			Build(),
+		NewResponse("GET", "/hello").
+			MatchQuery("lang", "ja").
+			Code(http.StatusOK).
+			ContentType("text/plain").
+			Body("こんにちは").
+			Build(),
+	)
+	server.Logger = t
+	defer server.Close()
+
+	resp, err := http.Get(fmt.Sprintf("%s/hello", server.URL))
+	if err != nil {
+		t.Fatalf("unexpected error : %+v", err)
+	}
+
+	if body := drainBody(t, resp); body != `{"message":"hello, world"}` {
+		t.Errorf("response body should be JSON: actual %s", body)
+	}
+
+	if ctype := resp.Header.Get("Content-Type"); ctype != "application/json" {
+		t.Errorf("Content-Type should be \"application/json\": actual %s", ctype)
+	}
+
+	if foo := resp.Header.Get("X-Foo"); foo != "bar" {
+		t.Errorf("X-Foo header should be \"bar\": actual %s", foo)
+	}
+
+	resp, err = http.Get(fmt.Sprintf("%s/hello?lang=ja", server.URL))
+	if err != nil {
+		t.Fatalf("unexpected error : %+v", err)
+	}
+
+	if body := drainBody(t, resp); body != "こんにちは" {
+		t.Errorf("response body should be \"こんにちは\": actual %s", body)
+	}
+}
+
+func TestResponseBuilderBuild(t *testing.T) {
+	b := NewResponse("GET", "/hello").Code(http.StatusOK).StatusText("200 Custom OK").Header("X-Foo", "foo")
+
+	first := b.Build()
+	second := b.Header("X-Bar", "bar").MatchHeader("Accept", "text/plain").MatchQuery("lang", "ja").Build()
+
+	if first.Code != http.StatusOK || first.Status != "200 Custom OK" {
+		t.Errorf("Code and Status should be set: actual %d %q", first.Code, first.Status)
+	}
+
+	if bar := first.Headers.Get("X-Bar"); bar != "" {
+		t.Errorf("headers of the response built first should not be modified: actual %s", bar)
+	}
+
+	if first.MatchHeaders != nil || first.MatchQuery != nil {
+		t.Errorf("matchers of the response built first should not be set: actual %v %v", first.MatchHeaders, first.MatchQuery)
+	}
+
+	second.Headers.Set("X-Foo", "modified")
+	if foo := b.Build().Headers.Get("X-Foo"); foo != "foo" {
+		t.Errorf("headers of the builder should not be modified: actual %s", foo)
+	}
+}
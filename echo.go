@@ -0,0 +1,42 @@
+package httpmocker
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+)
+
+// EchoResponse : JSON body written by mock responses added with AddEcho
+type EchoResponse struct {
+	Method  string      `json:"method"`
+	Path    string      `json:"path"`
+	Query   string      `json:"query"`
+	Headers http.Header `json:"headers"`
+	Body    string      `json:"body"`
+}
+
+// AddEcho : add mock response reflecting the request method, headers and body back as JSON
+func (server *Server) AddEcho(method, path string) *Server {
+	return server.AddResponses(Response{
+		Method:  method,
+		Path:    path,
+		Handler: echoHandler,
+	})
+}
+
+func echoHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(EchoResponse{
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Query:   r.URL.RawQuery,
+		Headers: r.Header,
+		Body:    string(body),
+	})
+}
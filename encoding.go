@@ -0,0 +1,78 @@
+package httpmocker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// acceptsGzip : returns true if given request advertises gzip support in Accept-Encoding
+func acceptsGzip(r *http.Request) bool {
+	// explicit gzip takes precedence over the wildcard, such as "*, gzip;q=0"
+	gzip, wildcard := -1.0, -1.0
+	for _, accept := range r.Header["Accept-Encoding"] {
+		for _, coding := range strings.Split(accept, ",") {
+			params := strings.Split(coding, ";")
+			switch strings.ToLower(strings.TrimSpace(params[0])) {
+			case "gzip":
+				gzip = qvalue(params[1:])
+			case "*":
+				wildcard = qvalue(params[1:])
+			}
+		}
+	}
+
+	if gzip >= 0 {
+		return gzip > 0
+	}
+
+	return wildcard > 0
+}
+
+// qvalue : returns the quality value in given parameters of Accept-* header
+func qvalue(params []string) float64 {
+	for _, param := range params {
+		param = strings.TrimSpace(param)
+		if !strings.HasPrefix(param, "q=") {
+			continue
+		}
+
+		q, err := strconv.ParseFloat(param[2:], 64)
+		if err != nil {
+			return 0
+		}
+
+		return q
+	}
+
+	return 1
+}
+
+// gzipBody : compresses given body with gzip
+func gzipBody(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write(body); err != nil {
+		return nil, err
+	}
+
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// gunzipBody : decompresses given body compressed with gzip
+func gunzipBody(body []byte) ([]byte, error) {
+	gr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	return ioutil.ReadAll(gr)
+}
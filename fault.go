@@ -0,0 +1,40 @@
+package httpmocker
+
+import (
+	"net"
+	"net/http"
+)
+
+// Fault : kind of connection failure simulated by mock server
+type Fault int
+
+const (
+	// FaultNone : respond normally
+	FaultNone Fault = iota
+	// FaultCloseConnection : reset the connection without writing any response
+	FaultCloseConnection
+	// FaultEmptyResponse : close the connection gracefully without writing any response
+	FaultEmptyResponse
+)
+
+// injectFault : hijacks the connection and closes it according to given fault
+func (server *Server) injectFault(w http.ResponseWriter, fault Fault) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		server.warnf("failed to inject fault : hijacking is not supported by %T", w)
+		return
+	}
+
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		server.warnf("failed to inject fault : %+v", err)
+		return
+	}
+
+	if tcpConn, ok := conn.(*net.TCPConn); ok && fault == FaultCloseConnection {
+		// discard unsent data and send RST
+		tcpConn.SetLinger(0)
+	}
+
+	conn.Close()
+}
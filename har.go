@@ -0,0 +1,168 @@
+package httpmocker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type harFile struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	Request struct {
+		Method string `json:"method"`
+		URL    string `json:"url"`
+	} `json:"request"`
+	Response struct {
+		Status  int         `json:"status"`
+		Headers []harHeader `json:"headers"`
+		Content struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+			Encoding string `json:"encoding"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+type harHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// headers which are not replayed because the mock server computes them by itself
+var harSkippedHeaders = map[string]bool{
+	"Content-Length":    true,
+	"Content-Encoding":  true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+}
+
+func (e harEntry) response() (Response, error) {
+	u, err := url.Parse(e.Request.URL)
+	if err != nil {
+		return Response{}, err
+	}
+
+	if e.Request.Method == "" || e.Response.Status == 0 {
+		return Response{}, fmt.Errorf("request method or response status is missing")
+	}
+
+	body, err := e.body()
+	if err != nil {
+		return Response{}, err
+	}
+
+	headers := http.Header{}
+	for _, h := range e.Response.Headers {
+		if !harSkippedHeaders[http.CanonicalHeaderKey(h.Name)] {
+			headers.Add(h.Name, h.Value)
+		}
+	}
+
+	return Response{
+		Method:      e.Request.Method,
+		Path:        u.Path,
+		Query:       u.RawQuery,
+		Code:        e.Response.Status,
+		ContentType: e.Response.Content.MimeType,
+		Body:        string(body),
+		Headers:     headers,
+	}, nil
+}
+
+// body : returns decoded response body, decompressing it if it's gzip-encoded
+func (e harEntry) body() ([]byte, error) {
+	content := e.Response.Content
+	body := []byte(content.Text)
+	if content.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(content.Text)
+		if err != nil {
+			return nil, err
+		}
+		body = decoded
+	}
+
+	gzipped := len(body) > 2 && body[0] == 0x1f && body[1] == 0x8b
+	for _, h := range e.Response.Headers {
+		if strings.EqualFold(h.Name, "Content-Encoding") && strings.EqualFold(h.Value, "gzip") {
+			gzipped = true
+		}
+	}
+
+	if !gzipped || len(body) == 0 {
+		return body, nil
+	}
+
+	gr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		// browsers usually store bodies already decompressed
+		return body, nil
+	}
+	defer gr.Close()
+
+	return ioutil.ReadAll(gr)
+}
+
+// loadHAR : reads mock responses from given HAR file, and returns descriptions of entries skipped since they can't be interpreted
+func loadHAR(path string) ([]Response, []string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, nil, fmt.Errorf("httpmocker: failed to parse %s : %v", path, err)
+	}
+
+	var responses []Response
+	var skipped []string
+	for i, entry := range har.Log.Entries {
+		resp, err := entry.response()
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("#%d %s %s (%v)", i, entry.Request.Method, entry.Request.URL, err))
+			continue
+		}
+		responses = append(responses, resp)
+	}
+
+	return responses, skipped, nil
+}
+
+// LaunchFromHAR : launch mock server replaying responses captured in given HTTP Archive (HAR) file.
+// Entries which can't be interpreted are skipped. Use LoadHAR to know them.
+func LaunchFromHAR(path string) (*Server, error) {
+	responses, _, err := loadHAR(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Launch(responses...), nil
+}
+
+// LoadHAR : add mock responses replaying responses captured in given HTTP Archive (HAR) file.
+// It returns the number of entries skipped since they can't be interpreted, which are logged to Logger.
+func (server *Server) LoadHAR(path string) (int, error) {
+	responses, skipped, err := loadHAR(path)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(skipped) > 0 {
+		server.warnf("skipped %d of %d entries in %s : %s",
+			len(skipped), len(responses)+len(skipped), path, strings.Join(skipped, ", "))
+	}
+	server.AddResponses(responses...)
+
+	return len(skipped), nil
+}
@@ -0,0 +1,88 @@
+package httpmocker
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLaunchFromHAR(t *testing.T) {
+	server, err := LaunchFromHAR("testdata/sample.har")
+	if err != nil {
+		t.Fatalf("unexpected error : %+v", err)
+	}
+	server.Logger = t
+	defer server.Close()
+
+	get := func(path string) (*http.Response, string) {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		return resp, string(body)
+	}
+
+	resp, body := get("/hello?lang=en")
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status code should be 200 OK : actual %d", resp.StatusCode)
+	}
+
+	if xh := resp.Header.Get("X-Custom-Header"); xh != "captured" {
+		t.Errorf("X-Custom-Header should be \"captured\": actual %s", xh)
+	}
+
+	if body != "hello, world" {
+		t.Errorf("response body should be \"hello, world\": actual %s", body)
+	}
+
+	// gzip-compressed and base64-encoded body should be decoded
+	resp, body = get("/sushi")
+	if ctype := resp.Header.Get("Content-Type"); ctype != "application/json" {
+		t.Errorf("ContentType should be application/json: actual %s", ctype)
+	}
+
+	if body != `{"name":"maguro"}` {
+		t.Errorf("response body should be decoded: actual %s", body)
+	}
+
+	// entries which can't be interpreted should be skipped
+	if n := len(server.Responses["GET"]); n != 2 {
+		t.Errorf("2 responses should be registered: actual %d", n)
+	}
+
+	if _, err := LaunchFromHAR("testdata/missing.har"); err == nil {
+		t.Errorf("missing file should be an error")
+	}
+}
+
+func TestLoadHAR(t *testing.T) {
+	server := Launch()
+	logs := make(chanLogger, 16)
+	server.Logger = logs
+	defer server.Close()
+
+	skipped, err := server.LoadHAR("testdata/sample.har")
+	if err != nil {
+		t.Fatalf("unexpected error : %+v", err)
+	}
+
+	if skipped != 1 {
+		t.Errorf("1 entry should be skipped: actual %d", skipped)
+	}
+
+	if msg := <-logs; !strings.HasPrefix(msg, "skipped 1 of 3 entries") {
+		t.Errorf("skipped entries should be logged: actual %s", msg)
+	}
+
+	if n := len(server.Responses["GET"]); n != 2 {
+		t.Errorf("2 responses should be registered: actual %d", n)
+	}
+}
@@ -0,0 +1,150 @@
+package httpmocker
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// idempotency : responses cached to be replayed for duplicate requests
+type idempotency struct {
+	header  string
+	window  time.Duration
+	entries map[string]*idempotentEntry
+}
+
+// idempotentEntry : response written for the first request of a key
+type idempotentEntry struct {
+	done   chan struct{} // closed when the first request has been served
+	at     time.Time
+	status int
+	header http.Header
+	body   []byte
+}
+
+// EnableIdempotency : replays the first response for duplicate requests received within given window.
+// Requests are duplicates if they have the same method, path and value of given header such as "Idempotency-Key".
+// Requests without the header are duplicates if they have the same method, path and body, except GET, HEAD and OPTIONS requests.
+// Duplicates received while the first request is in flight wait for its response.
+// Only responses of registered mock responses are replayed, so unknown requests are served again.
+// Replayed requests are recorded and counted in TotalRequests, but not counted as calls of mock responses.
+func (server *Server) EnableIdempotency(header string, window time.Duration) *Server {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.idempotency = &idempotency{
+		header:  http.CanonicalHeaderKey(header),
+		window:  window,
+		entries: map[string]*idempotentEntry{},
+	}
+
+	return server
+}
+
+// idempotencyKey : returns the key to detect duplicates of given request, or empty if idempotency is not enabled
+func (server *Server) idempotencyKey(r *http.Request) string {
+	server.mu.Lock()
+	idem := server.idempotency
+	server.mu.Unlock()
+
+	if idem == nil {
+		return ""
+	}
+
+	key := callKey(r.Method, r.URL.Path)
+	if v := r.Header.Get(idem.header); idem.header != "" && v != "" {
+		return key + " " + idem.header + ": " + v
+	}
+
+	switch r.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return ""
+	}
+
+	body, _ := bufferBody(r)
+	sum := sha256.Sum256(body)
+	return key + " " + hex.EncodeToString(sum[:])
+}
+
+// awaitIdempotent : returns the cached entry for given key, waiting for the in-flight request with the same key.
+// If there is none, it returns a pending entry with owner true, and the caller must pass it to storeResponse.
+func (server *Server) awaitIdempotent(ctx context.Context, key string) (entry *idempotentEntry, owner bool, err error) {
+	for {
+		server.mu.Lock()
+		if server.idempotency == nil {
+			server.mu.Unlock()
+			return nil, false, nil
+		}
+
+		entry = server.idempotency.entries[key]
+		if entry != nil && entry.served() && time.Since(entry.at) >= server.idempotency.window {
+			delete(server.idempotency.entries, key)
+			entry = nil
+		}
+		if entry == nil {
+			entry = &idempotentEntry{done: make(chan struct{})}
+			server.idempotency.entries[key] = entry
+			server.mu.Unlock()
+			return entry, true, nil
+		}
+		server.mu.Unlock()
+
+		if entry.served() {
+			return entry, false, nil
+		}
+
+		select {
+		case <-entry.done:
+			// look up again, since the response may not have been cached
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+}
+
+// served : returns true if the first request of the entry has been served
+func (entry *idempotentEntry) served() bool {
+	select {
+	case <-entry.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// replay : writes the cached response
+func (entry *idempotentEntry) replay(w http.ResponseWriter) {
+	for k, values := range entry.header {
+		w.Header()[k] = append([]string(nil), values...)
+	}
+	w.WriteHeader(entry.status)
+	w.Write(entry.body)
+}
+
+// storeResponse : caches the response written to given writer in given pending entry if matched is true,
+// otherwise discards the entry so that the next duplicate is served again
+func (server *Server) storeResponse(key string, entry *idempotentEntry, sw *statusWriter, matched bool) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	defer close(entry.done)
+
+	if matched && !sw.hijacked {
+		entry.at = time.Now()
+		entry.status = sw.statusCode()
+		entry.header = cloneHeader(sw.Header())
+		entry.body = append([]byte(nil), sw.body.Bytes()...)
+		return
+	}
+
+	if server.idempotency != nil && server.idempotency.entries[key] == entry {
+		delete(server.idempotency.entries, key)
+	}
+}
+
+// newCapturingWriter : returns statusWriter which also captures the body written
+func newCapturingWriter(w http.ResponseWriter) *statusWriter {
+	return &statusWriter{ResponseWriter: w, body: &bytes.Buffer{}}
+}
@@ -0,0 +1,157 @@
+package httpmocker
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEnableIdempotency(t *testing.T) {
+	server := Launch().
+		AddSequence("POST", "/payments",
+			Response{Code: http.StatusCreated, Body: "payment 1", Headers: http.Header{"X-Payment-Id": []string{"1"}}},
+			Response{Code: http.StatusCreated, Body: "payment 2", Headers: http.Header{"X-Payment-Id": []string{"2"}}},
+			Response{Code: http.StatusCreated, Body: "payment 3", Headers: http.Header{"X-Payment-Id": []string{"3"}}},
+		).
+		EnableIdempotency("Idempotency-Key", time.Minute)
+	server.Logger = t
+	defer server.Close()
+
+	post := func(key, body string) *http.Response {
+		req, err := http.NewRequest("POST", fmt.Sprintf("%s/payments", server.URL), strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		return resp
+	}
+
+	for i, c := range []struct {
+		key      string
+		body     string
+		expected string
+	}{
+		{"a", "100", "payment 1"},
+		{"a", "200", "payment 1"},
+		{"b", "100", "payment 2"},
+		{"", "300", "payment 3"},
+		{"", "300", "payment 3"},
+	} {
+		resp := post(c.key, c.body)
+		if body := drainBody(t, resp); body != c.expected {
+			t.Errorf("response body of request #%d should be %q: actual %s", i, c.expected, body)
+		}
+
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("status code of request #%d should be 201: actual %d", i, resp.StatusCode)
+		}
+
+		if id := resp.Header.Get("X-Payment-Id"); id != c.expected[len(c.expected)-1:] {
+			t.Errorf("X-Payment-Id of request #%d should be %s: actual %s", i, c.expected[len(c.expected)-1:], id)
+		}
+	}
+
+	if count := server.CallCount("POST", "/payments"); count != 3 {
+		t.Errorf("call count should be 3: actual %d", count)
+	}
+
+	server.Reset()
+
+	if body := drainBody(t, post("a", "100")); body != "payment 1" {
+		t.Errorf("response body after reset should be \"payment 1\": actual %s", body)
+	}
+	if body := drainBody(t, post("a", "100")); body != "payment 1" {
+		t.Errorf("response body of duplicate after reset should be \"payment 1\": actual %s", body)
+	}
+	if count := server.CallCount("POST", "/payments"); count != 1 {
+		t.Errorf("call count after reset should be 1: actual %d", count)
+	}
+}
+
+func TestIdempotencyConcurrentDuplicates(t *testing.T) {
+	server := Launch(
+		Response{Method: "POST", Path: "/payments", Code: http.StatusCreated, Body: "created", Delay: 50 * time.Millisecond},
+	).EnableIdempotency("Idempotency-Key", time.Minute)
+	server.Logger = t
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			req, err := http.NewRequest("POST", fmt.Sprintf("%s/payments", server.URL), strings.NewReader("pay"))
+			if err != nil {
+				t.Errorf("unexpected error : %+v", err)
+				return
+			}
+			req.Header.Set("Idempotency-Key", "key-1")
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Errorf("unexpected error : %+v", err)
+				return
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusCreated {
+				t.Errorf("status code should be 201 Created: actual %d", resp.StatusCode)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if count := server.CallCount("POST", "/payments"); count != 1 {
+		t.Errorf("concurrent duplicates should be served once: actual %d", count)
+	}
+
+	if total := server.TotalRequests(); total != 5 {
+		t.Errorf("replayed requests should be counted in total requests: actual %d", total)
+	}
+}
+
+func TestIdempotencyUnknownRequest(t *testing.T) {
+	server := Launch().EnableIdempotency("Idempotency-Key", time.Minute)
+	server.Logger = t
+	defer server.Close()
+
+	post := func() *http.Response {
+		req, err := http.NewRequest("POST", fmt.Sprintf("%s/payments", server.URL), strings.NewReader("pay"))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		req.Header.Set("Idempotency-Key", "key-1")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		drainBody(t, resp)
+
+		return resp
+	}
+
+	server.UnknownRequestHandler = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}
+	if resp := post(); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status code should be 404 Not Found: actual %d", resp.StatusCode)
+	}
+
+	// the response of unknown request should not be replayed
+	server.Add("POST", "/payments", http.StatusCreated, "created")
+	if resp := post(); resp.StatusCode != http.StatusCreated {
+		t.Errorf("status code should be 201 Created after registered: actual %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,73 @@
+package httpmocker
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// mustParseJSON : parses given JSON document, panics if it is invalid
+func mustParseJSON(s string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		panic(fmt.Sprintf("httpmocker: invalid MatchJSON %q : %v", s, err))
+	}
+
+	return v
+}
+
+// matchJSON : returns true if given body is a JSON document containing expected value
+func matchJSON(expected interface{}, body []byte) bool {
+	var actual interface{}
+	if err := json.Unmarshal(body, &actual); err != nil {
+		return false
+	}
+
+	return containsJSON(actual, expected)
+}
+
+// containsJSON : returns true if actual is a superset of expected.
+// Objects match when actual has every key of expected with a matching value,
+// arrays match when every element of expected matches some element of actual.
+func containsJSON(actual, expected interface{}) bool {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			return false
+		}
+
+		for k, ev := range e {
+			av, ok := a[k]
+			if !ok || !containsJSON(av, ev) {
+				return false
+			}
+		}
+
+		return true
+
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok {
+			return false
+		}
+
+		for _, ev := range e {
+			found := false
+			for _, av := range a {
+				if containsJSON(av, ev) {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				return false
+			}
+		}
+
+		return true
+
+	default:
+		return actual == expected
+	}
+}
@@ -0,0 +1,97 @@
+package httpmocker
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+)
+
+// LogToFile : writes timestamped logs of mock server to given file in addition to Logger,
+// with a line of method, path, status and duration for each request. The file is closed when mock server is closed.
+func (server *Server) LogToFile(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("httpmocker: failed to open log file %s : %v", path, err)
+	}
+
+	server.fileLoggerMu.Lock()
+	server.fileLogger = log.New(f, "", log.LstdFlags|log.Lmicroseconds)
+	server.fileLoggerMu.Unlock()
+
+	server.onClose(func() {
+		server.fileLoggerMu.Lock()
+		server.fileLogger = nil
+		server.fileLoggerMu.Unlock()
+
+		f.Close()
+	})
+
+	return nil
+}
+
+func (server *Server) getFileLogger() *log.Logger {
+	server.fileLoggerMu.RLock()
+	defer server.fileLoggerMu.RUnlock()
+
+	return server.fileLogger
+}
+
+func (server *Server) logToFile(msg string, args ...interface{}) {
+	if l := server.getFileLogger(); l != nil {
+		l.Printf(msg, args...)
+	}
+}
+
+// statusWriter : http.ResponseWriter which remembers the status code written, and the body if body is not nil
+type statusWriter struct {
+	http.ResponseWriter
+	status   int
+	body     *bytes.Buffer
+	hijacked bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.body != nil {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("httpmocker: hijacking is not supported by %T", w.ResponseWriter)
+	}
+
+	w.hijacked = true
+	return hijacker.Hijack()
+}
+
+// statusCode : returns the status code written, or 200 if nothing has been written
+func (w *statusWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+
+	return w.status
+}
@@ -0,0 +1,59 @@
+package httpmocker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogToFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpmocker")
+	if err != nil {
+		t.Fatalf("unexpected error : %+v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "mock.log")
+
+	server := Launch(
+		Response{Method: "GET", Path: "/hello", Code: http.StatusOK, Body: "hello, world"},
+	)
+	server.Logger = t
+	if err := server.LogToFile(path); err != nil {
+		t.Fatalf("unexpected error : %+v", err)
+	}
+
+	for _, p := range []string{"/hello", "/unknown"} {
+		resp, err := http.Get(fmt.Sprintf("%s%s", server.URL, p))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		resp.Body.Close()
+	}
+	server.Close()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error : %+v", err)
+	}
+	log := string(data)
+
+	for _, expected := range []string{
+		"handler : GET /hello",
+		"GET /hello -> 200 (",
+		"unknown request: GET /unknown",
+		"GET /unknown -> 200 (",
+	} {
+		if !strings.Contains(log, expected) {
+			t.Errorf("log file should contain %q: actual %s", expected, log)
+		}
+	}
+
+	if err := server.LogToFile(filepath.Join(dir, "missing", "mock.log")); err == nil {
+		t.Errorf("LogToFile should fail if the directory does not exist")
+	}
+}
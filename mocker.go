@@ -1,9 +1,24 @@
 package httpmocker
 
 import (
-	"io"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
 )
 
 // Server : mock server object
@@ -13,19 +28,213 @@ type Server struct {
 	URL       string
 	Logger
 	UnknownRequestHandler http.HandlerFunc
+
+	// MaxBodySize : requests with larger body get 413 Request Entity Too Large. Zero means unlimited.
+	MaxBodySize int64
+
+	// DefaultContentType : Content-Type of responses without ContentType, used instead of sniffing the body.
+	// It is also set before Handler runs, and Handler can override it.
+	DefaultContentType string
+
+	// ContextFunc : returns the context of each request passed to matchers and handlers
+	ContextFunc func(ctx context.Context, r *http.Request) context.Context
+
+	// Before : called with the request and a copy of the selected mock response before writing it.
+	// Changes to the copy such as Body and Headers apply only to this request, and BodyTemplate and Variants are compiled again.
+	// The body is taken from the first one set of JSONBody, BodyTemplate, BodyBytes, Body and BodyFile, and a matching variant replaces it,
+	// so clear the preceding ones to change Body.
+	Before func(r *http.Request, resp *Response)
+
+	// OnMatch : called with the request and the selected mock response every time a response is matched
+	OnMatch func(r *http.Request, resp *Response)
+
+	responsesMu      sync.RWMutex // guards Responses, defaultResp, mirrorHEAD, autoOptions, disableKeepAlive, proxyTarget and proxy
+	defaultResp      *Response
+	mirrorHEAD       bool
+	autoOptions      bool
+	disableKeepAlive bool
+	proxyTarget      *url.URL
+	proxy            http.Handler
+
+	mu            sync.Mutex // guards callCounts, totalRequests, durations, lastDuration, unmatched, requests, requested, sequenceIndexes, consumed, idempotency and rateLimits
+	callCounts    map[string]int
+	totalRequests int
+	durations     map[string]*durationStat
+	lastDuration  time.Duration
+	unmatched     []string
+	requests      []RecordedRequest
+	requested     *sync.Cond // broadcast when a request is recorded
+
+	sequenceIndexes map[*Response]int
+	consumed        map[*Response]bool // responses with Once which have been served
+	idempotency     *idempotency
+	rateLimits      map[string]*rateLimit
+
+	closersMu sync.Mutex // guards closers
+	closers   []func()
+
+	fileLoggerMu sync.RWMutex // guards fileLogger
+	fileLogger   *log.Logger
+
+	middlewaresMu sync.RWMutex // guards middlewares
+	middlewares   []func(http.Handler) http.Handler
+
+	randMu sync.Mutex // guards rand
+	rand   *rand.Rand
 }
 
 // Response : mocke response
 type Response struct {
 	Method      string
-	Path        string
+	Path        string // trailing "/*" or "/**" matches any path under the prefix
 	Query       string
-	Code        int
+	Code        int    // zero means 200 OK
+	Status      string // status line such as "200 Custom OK" instead of the standard reason phrase, ignoring Chunks, Trickle and Trailers
 	ContentType string
 	Body        string
 	Headers     http.Header
 
+	// BasicAuthUser, BasicAuthPassword : if BasicAuthUser is set, requests without these credentials get 401
+	BasicAuthUser     string
+	BasicAuthPassword string
+
+	// Cookies : cookies set by Set-Cookie headers
+	Cookies []*http.Cookie
+
+	// ReflectHeaders : request headers such as X-Request-Id copied into response headers if present
+	ReflectHeaders []string
+
+	// Trailers : trailers declared by Trailer header and sent after the body
+	Trailers http.Header
+
+	// MatchQuery : query parameters required for this response to match regardless of order.
+	// Values may contain "*" wildcards, and "*" alone matches any value of a present parameter.
+	// A response with exact Query wins over one with MatchQuery when both match.
+	MatchQuery url.Values
+
+	// MatchHost : request host required for this response to match. Port is ignored unless MatchHost contains it.
+	MatchHost string
+
+	// MatchHeaders : request headers required for this response to match
+	MatchHeaders http.Header
+
+	// MatchContentType : media type such as "application/json" or "multipart/*" which Content-Type of the request must match.
+	// Parameters of Content-Type such as charset are ignored.
+	MatchContentType string
+
+	// MatchCookies : request cookies required for this response to match, keyed by name
+	MatchCookies map[string]string
+
+	// MatchBody : request body required for this response to match
+	MatchBody string
+	// MatchBodyContains : if true, MatchBody matches when the request body contains it
+	MatchBodyContains bool
+
+	// MatchJSON : JSON document which the request body must be a superset of
+	MatchJSON string
+
+	// RequestSchema : JSON Schema which the request body must satisfy. Requests violating it get 400 Bad Request
+	// with the validation errors instead of this response.
+	RequestSchema string
+
+	// Match : custom matcher evaluated after method and path match
+	Match func(*http.Request) bool
+
+	// PathRegex : if true, Path is interpreted as a regular expression which must match the whole request path.
+	// Named groups such as `(?P<id>\d+)` and placeholders such as `{id}` are captured as path parameters.
+	PathRegex bool
+
+	// Delay : duration to wait before responding
+	Delay time.Duration
+	// DelayMin, DelayMax : range of random duration to wait before responding. Delay is ignored if set.
+	DelayMin time.Duration
+	DelayMax time.Duration
+
+	// Fault : connection failure simulated instead of writing response
+	Fault Fault
+
+	// RandomCodes : status codes one of which is chosen at random per request. Code is ignored if set.
+	RandomCodes []int
+
+	// BodyBytes : binary response body which takes precedence over Body when non-nil
+	BodyBytes []byte
+
+	// Gzip : if true, compress response body with gzip when the request accepts it
+	Gzip bool
+
+	// Chunks : response body written in pieces, flushing after each piece. Body is ignored if set.
+	Chunks []string
+	// ChunkDelay : duration to wait between Chunks
+	ChunkDelay time.Duration
+
+	// Trickle : duration to wait between each byte of response body
+	Trickle time.Duration
+
+	// JSONBody : value marshaled as JSON response body when non-nil
+	JSONBody interface{}
+
+	// BodyTemplate : text/template executed against TemplateData of the request to build response body
+	BodyTemplate string
+
+	// BodyFile : path of the file served as response body when Body is empty.
+	// A file ending with ".gz" is served as is with Content-Encoding: gzip to clients accepting gzip, and decompressed otherwise.
+	BodyFile string
+
+	// Weight : relative probability of being chosen among equally ranked responses. Zero means 1.
+	// If no response has Weight, registration order decides as described in selectResponse.
+	Weight int
+
+	// Variants : responses keyed by media type such as "application/json", chosen by Accept header of the request.
+	// Content-Type is set to the key unless the variant has ContentType.
+	// The variant replaces the body of this response, while other settings such as Headers, Cookies and Gzip are kept.
+	// The variant keyed by "*/*" is used if no other variant is acceptable, and this response is used if it is missing either.
+	Variants map[string]Response
+
+	// Once : if true, the response is served only once, and then excluded from matching until Reset
+	Once bool
+
+	// Priority : responses with higher priority win when several responses match.
+	// Equal priorities fall back to the other matchers, then to registration order.
+	Priority int
+
+	// Inspect : called with the request before writing response, even if Handler is set
+	Inspect func(*http.Request)
+
 	Handler http.HandlerFunc
+
+	pathRegexp *regexp.Regexp
+	pathPrefix string
+	matchJSON  interface{}
+
+	requestSchema *schema
+	variants      map[string]*Response
+	bodyTemplate  *template.Template
+	sequence      []*Response
+	roundRobin    bool // sequence wraps around instead of sticking on the last response
+}
+
+type contextKey struct {
+	name string
+}
+
+// PathParamsKey : context key for path parameters captured by regex path
+var PathParamsKey = &contextKey{"path-params"}
+
+// PathSuffixKey : context key for the path suffix matched by trailing wildcard
+var PathSuffixKey = &contextKey{"path-suffix"}
+
+var pathPlaceholder = regexp.MustCompile(`\{([a-zA-Z_][a-zA-Z0-9_]*)\}`)
+
+// PathParams : returns path parameters captured by regex path
+func PathParams(r *http.Request) map[string]string {
+	params, _ := r.Context().Value(PathParamsKey).(map[string]string)
+	return params
+}
+
+// PathSuffix : returns the path suffix matched by trailing wildcard such as "/static/*"
+func PathSuffix(r *http.Request) string {
+	suffix, _ := r.Context().Value(PathSuffixKey).(string)
+	return suffix
 }
 
 // Logger : logger for mock server
@@ -33,11 +242,63 @@ type Logger interface {
 	Logf(string, ...interface{})
 }
 
+// LevelLogger : logger with log levels. If Logger implements it, mock server logs
+// matched requests as debug and unknown requests or errors as warnings.
+type LevelLogger interface {
+	Logger
+	Debugf(string, ...interface{})
+	Infof(string, ...interface{})
+	Warnf(string, ...interface{})
+}
+
 // Close : shutdown mock server
 func (server *Server) Close() {
 	if server.Server != nil {
 		server.Server.Close()
 	}
+	server.runClosers()
+}
+
+// onClose : registers given function to be called when mock server is closed
+func (server *Server) onClose(f func()) {
+	server.closersMu.Lock()
+	defer server.closersMu.Unlock()
+
+	server.closers = append(server.closers, f)
+}
+
+// runClosers : calls functions registered by onClose in reverse order
+func (server *Server) runClosers() {
+	server.closersMu.Lock()
+	closers := server.closers
+	server.closers = nil
+	server.closersMu.Unlock()
+
+	for i := len(closers) - 1; i >= 0; i-- {
+		closers[i]()
+	}
+}
+
+// CloseWithTimeout : shutdown mock server gracefully, and forcibly close connections after given timeout.
+// It returns an error if requests are still in flight after the timeout.
+func (server *Server) CloseWithTimeout(d time.Duration) error {
+	defer server.runClosers()
+
+	if server.Server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+
+	err := server.Server.Config.Shutdown(ctx)
+	if err != nil {
+		server.Server.CloseClientConnections()
+		err = fmt.Errorf("httpmocker: requests are still in flight after %s : %v", d, err)
+	}
+	server.Server.Close()
+
+	return err
 }
 
 // Add : add mock response to mock server
@@ -52,6 +313,31 @@ func (server *Server) Add(method, path string, code int, body string) *Server {
 	return server
 }
 
+// Get : add mock response for GET requests to mock server
+func (server *Server) Get(path string, code int, body string) *Server {
+	return server.Add("GET", path, code, body)
+}
+
+// Post : add mock response for POST requests to mock server
+func (server *Server) Post(path string, code int, body string) *Server {
+	return server.Add("POST", path, code, body)
+}
+
+// Put : add mock response for PUT requests to mock server
+func (server *Server) Put(path string, code int, body string) *Server {
+	return server.Add("PUT", path, code, body)
+}
+
+// Patch : add mock response for PATCH requests to mock server
+func (server *Server) Patch(path string, code int, body string) *Server {
+	return server.Add("PATCH", path, code, body)
+}
+
+// Delete : add mock response for DELETE requests to mock server
+func (server *Server) Delete(path string, code int, body string) *Server {
+	return server.Add("DELETE", path, code, body)
+}
+
 // AddEmptyResponse : add empyt mock response to mock server
 func (server *Server) AddEmptyResponse(method, path string, code int) *Server {
 	server.AddResponses(Response{
@@ -63,122 +349,1325 @@ func (server *Server) AddEmptyResponse(method, path string, code int) *Server {
 	return server
 }
 
+// AddError : add mock response which always fails with given status code and plain text message
+func (server *Server) AddError(method, path string, code int, message string) *Server {
+	return server.AddResponses(Response{
+		Method:      method,
+		Path:        path,
+		Code:        code,
+		ContentType: "text/plain",
+		Body:        message,
+	})
+}
+
+// AddRedirect : add mock response redirecting to given location. It panics if code is not 3xx.
+func (server *Server) AddRedirect(method, path, location string, code int) *Server {
+	if code < 300 || code > 399 {
+		panic(fmt.Sprintf("httpmocker: redirect status code must be 3xx : %d", code))
+	}
+
+	return server.AddResponses(Response{
+		Method:  method,
+		Path:    path,
+		Code:    code,
+		Headers: http.Header{"Location": []string{location}},
+	})
+}
+
+// AddMulti : add given mock response for each of given methods with given path
+func (server *Server) AddMulti(methods []string, path string, resp Response) *Server {
+	responses := make([]Response, len(methods))
+	for i, method := range methods {
+		responses[i] = resp
+		responses[i].Method = method
+		responses[i].Path = path
+	}
+
+	return server.AddResponses(responses...)
+}
+
 // AddResponses : add mock response to mock server
 func (server *Server) AddResponses(responses ...Response) *Server {
+	server.responsesMu.Lock()
+	defer server.responsesMu.Unlock()
+
+	// Server may be constructed without Launch
+	if server.Responses == nil {
+		server.Responses = map[string]map[string][]*Response{}
+	}
 
 	for _, response := range responses {
-		r := response
-		m := server.Responses[r.Method]
-		if m == nil {
-			m = map[string][]*Response{}
-			server.Responses[r.Method] = m
-		}
-		resps := m[r.Path]
-		if resps == nil {
-			m[r.Path] = []*Response{&r}
-			continue
+		appendResponse(server.Responses, compileResponse(response))
+	}
+
+	return server
+}
+
+// appendResponse : appends given compiled response to responses keyed by method and path
+func appendResponse(responses map[string]map[string][]*Response, r *Response) {
+	m := responses[r.Method]
+	if m == nil {
+		m = map[string][]*Response{}
+		responses[r.Method] = m
+	}
+
+	m[r.Path] = append(m[r.Path], r)
+}
+
+// Set : add mock response with given method and path, replacing the responses registered with the same method, path and Query.
+// The new response takes the place of the first replaced one, so the registration order of other responses is kept.
+// Use AddResponses to keep existing responses and append.
+func (server *Server) Set(method, path string, resp Response) *Server {
+	server.responsesMu.Lock()
+	defer server.responsesMu.Unlock()
+
+	if server.Responses == nil {
+		server.Responses = map[string]map[string][]*Response{}
+	}
+
+	resp.Method = method
+	resp.Path = path
+	r := compileResponse(resp)
+
+	m := server.Responses[r.Method]
+	if m == nil {
+		m = map[string][]*Response{}
+		server.Responses[r.Method] = m
+	}
+
+	resps := make([]*Response, 0, len(m[path])+1)
+	var removed []*Response
+	for _, existing := range m[path] {
+		switch {
+		case existing.Query != r.Query:
+			resps = append(resps, existing)
+		case len(removed) == 0:
+			resps = append(resps, r)
+			removed = append(removed, existing)
+		default:
+			removed = append(removed, existing)
 		}
+	}
+	if len(removed) == 0 {
+		resps = append(resps, r)
+	}
+	m[path] = resps
+
+	server.forget(removed)
+
+	return server
+}
+
+// forget : drops sequence positions and Once state of given responses which are no longer registered
+func (server *Server) forget(resps []*Response) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	for _, resp := range resps {
+		delete(server.sequenceIndexes, resp)
+		delete(server.consumed, resp)
+	}
+}
+
+// compileResponse : returns a copy of given response with its method normalized and its patterns compiled
+func compileResponse(response Response) *Response {
+	r := response
+	r.Method = strings.ToUpper(r.Method)
+	// compiled again from scratch, since the response may be a copy of compiled one
+	r.pathPrefix, r.pathRegexp, r.matchJSON, r.requestSchema, r.bodyTemplate, r.variants = "", nil, nil, nil, nil, nil
+	if !r.PathRegex {
+		r.pathPrefix = wildcardPrefix(r.Path)
+	}
+	if r.PathRegex {
+		pattern := pathPlaceholder.ReplaceAllString(r.Path, "(?P<$1>[^/]+)")
+		r.pathRegexp = regexp.MustCompile("^(?:" + pattern + ")$")
+	}
+	if r.MatchJSON != "" {
+		r.matchJSON = mustParseJSON(r.MatchJSON)
+	}
+	if r.RequestSchema != "" {
+		r.requestSchema = mustParseSchema(r.RequestSchema)
+	}
+	if r.BodyTemplate != "" {
+		r.bodyTemplate = mustParseTemplate(r.Path, r.BodyTemplate)
+	}
+	if len(r.Variants) > 0 {
+		r.variants = r.compileVariants()
+	}
+
+	return &r
+}
+
+// Remove : remove all mock responses registered with given method and path
+func (server *Server) Remove(method, path string) *Server {
+	server.responsesMu.Lock()
+	defer server.responsesMu.Unlock()
 
-		m[r.Path] = append(m[r.Path], &r)
+	if m := server.Responses[strings.ToUpper(method)]; m != nil {
+		delete(m, path)
 	}
 
 	return server
 }
 
-func (server *Server) findResponse(r *http.Request) *Response {
-	method := r.Method
-	path := r.URL.Path
+// RemoveResponse : remove mock responses registered with given method, path and query
+func (server *Server) RemoveResponse(method, path, query string) *Server {
+	server.responsesMu.Lock()
+	defer server.responsesMu.Unlock()
 
-	m := server.Responses[method]
+	m := server.Responses[strings.ToUpper(method)]
 	if m == nil {
-		return nil
+		return server
+	}
+
+	resps := m[path][:0]
+	for _, resp := range m[path] {
+		if resp.Query != query {
+			resps = append(resps, resp)
+		}
 	}
 
-	resps := m[path]
 	if len(resps) == 0 {
-		return nil
+		delete(m, path)
+	} else {
+		m[path] = resps
 	}
 
-	var candidate *Response
-	for _, resp := range resps {
-		if resp.Path == path {
-			if resp.Query == "" {
-				candidate = resp
-			}
+	return server
+}
 
-			if resp.Query != "" && resp.Query == r.URL.RawQuery {
-				return resp
-			}
-		}
+// Clear : remove all mock responses, recorded requests and call counts without restarting mock server
+func (server *Server) Clear() *Server {
+	server.responsesMu.Lock()
+	server.Responses = map[string]map[string][]*Response{}
+	server.responsesMu.Unlock()
+
+	server.Reset()
+
+	return server
+}
+
+// JSONUnknownHandler : returns handler for UnknownRequestHandler which responds 404 Not Found
+// with JSON such as {"error":"no mock for GET /x"}
+func (server *Server) JSONUnknownHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, _ := json.Marshal(map[string]string{
+			"error": fmt.Sprintf("no mock for %s %s", r.Method, r.URL.Path),
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(body)
 	}
+}
+
+// SetDefaultResponse : set mock response served for unknown requests when UnknownRequestHandler is not set.
+// It takes precedence over ProxyTo.
+func (server *Server) SetDefaultResponse(resp Response) *Server {
+	server.responsesMu.Lock()
+	defer server.responsesMu.Unlock()
 
-	return candidate
+	server.defaultResp = &resp
+	return server
 }
 
-func (server *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
-	method := r.Method
-	path := r.URL.Path
+func (server *Server) defaultResponse() *Response {
+	server.responsesMu.RLock()
+	defer server.responsesMu.RUnlock()
 
-	resp := server.findResponse(r)
+	return server.defaultResp
+}
 
-	// not found
-	if resp == nil {
-		server.logf("unknown request: %s %s", method, path)
-		if server.UnknownRequestHandler != nil {
-			server.UnknownRequestHandler(w, r)
+// MirrorHEAD : if enabled, HEAD requests without HEAD mock responses are answered
+// with the status and headers of GET mock responses, without the body
+func (server *Server) MirrorHEAD(enabled bool) *Server {
+	server.responsesMu.Lock()
+	defer server.responsesMu.Unlock()
+
+	server.mirrorHEAD = enabled
+	return server
+}
+
+// AutoOptions : if enabled, OPTIONS requests without OPTIONS mock responses are answered
+// with Allow header listing the methods registered for the path
+func (server *Server) AutoOptions(enabled bool) *Server {
+	server.responsesMu.Lock()
+	defer server.responsesMu.Unlock()
+
+	server.autoOptions = enabled
+	return server
+}
+
+func (server *Server) autoOptionsEnabled() bool {
+	server.responsesMu.RLock()
+	defer server.responsesMu.RUnlock()
+
+	return server.autoOptions
+}
+
+// DisableKeepAlives : closes the connection after each response, so that every request uses a new connection
+func (server *Server) DisableKeepAlives() *Server {
+	server.responsesMu.Lock()
+	server.disableKeepAlive = true
+	server.responsesMu.Unlock()
+
+	if server.Server != nil {
+		server.Server.Config.SetKeepAlivesEnabled(false)
+	}
+
+	return server
+}
+
+func (server *Server) keepAliveDisabled() bool {
+	server.responsesMu.RLock()
+	defer server.responsesMu.RUnlock()
+
+	return server.disableKeepAlive
+}
+
+// Len : returns the number of registered mock responses
+func (server *Server) Len() int {
+	server.responsesMu.RLock()
+	defer server.responsesMu.RUnlock()
+
+	n := 0
+	for _, m := range server.Responses {
+		for _, resps := range m {
+			n += len(resps)
 		}
+	}
 
-		return
+	return n
+}
+
+// Match : returns the mock response selected for given request without serving it, or nil if nothing matches.
+// It does not count the request or advance sequences.
+func (server *Server) Match(r *http.Request) *Response {
+	return server.findResponse(r)
+}
+
+func (server *Server) findResponse(r *http.Request) *Response {
+	server.responsesMu.RLock()
+	defer server.responsesMu.RUnlock()
+
+	if server.Responses == nil {
+		return nil
 	}
 
-	// Send response.
+	method := strings.ToUpper(r.Method)
+	resp := server.findResponseByMethod(method, r)
+	if resp == nil && method == "HEAD" && server.mirrorHEAD {
+		resp = server.findResponseByMethod("GET", r)
+	}
 
-	if resp.Handler != nil {
-		// if Handler is set, delegate response
-		resp.Handler(w, r)
-		return
+	return resp
+}
+
+// findResponseByMethod : finds mock response registered with given method. responsesMu must be held.
+func (server *Server) findResponseByMethod(method string, r *http.Request) *Response {
+	path := r.URL.Path
+
+	m := server.Responses[method]
+	if m == nil {
+		return nil
 	}
 
-	header := w.Header()
-	header.Set("Content-Type", resp.ContentType)
-	if resp.Headers != nil {
-		for k := range resp.Headers {
-			v := resp.Headers.Get(k)
-			header.Set(k, v)
-		}
+	body, err := bufferBody(r)
+	if err != nil {
+		server.warnf("failed to read request body : %s %s -> %+v", method, path, err)
 	}
-	if resp.Code != 0 {
-		w.WriteHeader(resp.Code)
+
+	// exact path matches take priority over regex matches unless Priority is higher
+	best := server.selectResponse(m[path], r, body)
+
+	patterns := make([]string, 0, len(m))
+	for pattern := range m {
+		if pattern != path {
+			patterns = append(patterns, pattern)
+		}
 	}
+	// longer patterns are more specific
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
 
-	io.WriteString(w, resp.Body)
+	for _, pattern := range patterns {
+		resp := server.selectResponse(m[pattern], r, body)
+		if resp != nil && (best == nil || resp.Priority > best.Priority) {
+			best = resp
+		}
+	}
 
-	server.logf("handler : %s %s -> %+v", method, path, resp)
-	return
+	return best
 }
 
-func (server *Server) logf(msg string, args ...interface{}) {
-	if server.Logger != nil {
-		server.Logger.Logf(msg, args...)
+// allowedMethods : returns sorted methods which have mock responses matching given path
+func (server *Server) allowedMethods(path string) []string {
+	server.responsesMu.RLock()
+	defer server.responsesMu.RUnlock()
+
+	var methods []string
+	for method, m := range server.Responses {
+		if hasPath(m, path) {
+			methods = append(methods, method)
+		}
 	}
+	sort.Strings(methods)
+
+	return methods
 }
 
-// Start : start up mock server
-func (server *Server) Start() *Server {
-	httptestserver := httptest.NewServer(
-		http.HandlerFunc(server.handleRequest),
-	)
-	server.Server = httptestserver
-	server.URL = httptestserver.URL
-	return server
+func hasPath(m map[string][]*Response, path string) bool {
+	for _, resps := range m {
+		for _, resp := range resps {
+			if resp.matchPath(path) {
+				return true
+			}
+		}
+	}
+
+	return false
 }
 
-// Launch : launch mock server with given mock requests
-func Launch(responses ...Response) *Server {
-	server := Server{}
-	server.Responses = map[string]map[string][]*Response{}
-	server.AddResponses(responses...)
-	server.Start()
+// selectResponse : returns the most specific response matching given request.
+// Higher Priority wins, then MatchHost over any host, then exact Query over MatchQuery over no query matcher, then the response with more matchers.
+// Among equally ranked responses, one is chosen at random in proportion to Weight if any of them has Weight.
+// Otherwise the one registered last wins, so that a mock can be overridden by adding another one,
+// except that the one registered first wins among responses with exact Query.
+func (server *Server) selectResponse(resps []*Response, r *http.Request, body []byte) *Response {
+	var best []*Response
+	for _, resp := range resps {
+		if !resp.matchPath(r.URL.Path) || !resp.matchQuery(r.URL.Query()) || !resp.matchHeaders(r.Header) || !resp.matchBody(body) {
+			continue
+		}
 
-	return &server
+		if resp.Query != "" && resp.Query != r.URL.RawQuery {
+			continue
+		}
+
+		if !resp.matchHost(r.Host) {
+			continue
+		}
+
+		if !resp.matchCookies(r) {
+			continue
+		}
+
+		if resp.MatchContentType != "" && !matchMediaRange(strings.ToLower(resp.MatchContentType), r.Header.Get("Content-Type")) {
+			continue
+		}
+
+		if resp.Match != nil && !resp.Match(r) {
+			continue
+		}
+
+		if resp.Once && server.isConsumed(resp) {
+			continue
+		}
+
+		switch {
+		case len(best) == 0 || resp.score().greater(best[0].score()):
+			best = []*Response{resp}
+		case !best[0].score().greater(resp.score()):
+			best = append(best, resp)
+		}
+	}
+
+	if len(best) == 0 {
+		return nil
+	}
+
+	if resp := server.weighted(best); resp != nil {
+		return resp
+	}
+	if best[0].Query != "" {
+		return best[0]
+	}
+
+	return best[len(best)-1]
+}
+
+// score : rank of a matched response. Fields are compared in order, and a higher value wins.
+type score struct {
+	priority int
+	host     int // 1 : MatchHost, 0 : any host
+	query    int // 2 : exact Query, 1 : MatchQuery, 0 : no query matcher
+	matchers int // number of MatchQuery parameters and other matchers
+}
+
+func (s score) greater(other score) bool {
+	if s.priority != other.priority {
+		return s.priority > other.priority
+	}
+
+	if s.host != other.host {
+		return s.host > other.host
+	}
+
+	if s.query != other.query {
+		return s.query > other.query
+	}
+
+	return s.matchers > other.matchers
+}
+
+// score : returns the rank of the response, assuming it matches the request
+func (resp *Response) score() score {
+	s := score{
+		priority: resp.Priority,
+		matchers: len(resp.MatchQuery) + resp.specificity(),
+	}
+
+	if resp.MatchHost != "" {
+		s.host = 1
+	}
+
+	switch {
+	case resp.Query != "":
+		s.query = 2
+	case len(resp.MatchQuery) > 0:
+		s.query = 1
+	}
+
+	return s
+}
+
+// matchPath : returns true if given path matches Path, the compiled pattern if PathRegex is set,
+// or the prefix if Path ends with a wildcard
+func (resp *Response) matchPath(path string) bool {
+	if resp.pathRegexp != nil {
+		return resp.pathRegexp.MatchString(path)
+	}
+
+	if resp.pathPrefix != "" {
+		return strings.HasPrefix(path, resp.pathPrefix)
+	}
+
+	return resp.Path == path
+}
+
+// wildcardPrefix : returns the prefix of given path if it ends with "/*" or "/**"
+func wildcardPrefix(path string) string {
+	for _, wildcard := range []string{"/**", "/*"} {
+		if strings.HasSuffix(path, wildcard) {
+			return strings.TrimSuffix(path, wildcard) + "/"
+		}
+	}
+
+	return ""
+}
+
+// pathParams : returns named groups captured from given path
+func (resp *Response) pathParams(path string) map[string]string {
+	params := map[string]string{}
+	if resp.pathRegexp == nil {
+		return params
+	}
+
+	match := resp.pathRegexp.FindStringSubmatch(path)
+	for i, name := range resp.pathRegexp.SubexpNames() {
+		if name != "" && i < len(match) {
+			params[name] = match[i]
+		}
+	}
+
+	return params
+}
+
+// matchBody : returns true if given body matches MatchBody and MatchJSON
+func (resp *Response) matchBody(body []byte) bool {
+	// matchJSON is nil if MatchJSON is "null", which requires the body to be JSON null
+	if resp.MatchJSON != "" && !matchJSON(resp.matchJSON, body) {
+		return false
+	}
+
+	if resp.MatchBody == "" {
+		return true
+	}
+
+	if resp.MatchBodyContains {
+		return bytes.Contains(body, []byte(resp.MatchBody))
+	}
+
+	return string(body) == resp.MatchBody
+}
+
+// specificity : returns the number of matchers other than method, path and query
+func (resp *Response) specificity() int {
+	n := len(resp.MatchHeaders) + len(resp.MatchCookies)
+	if resp.MatchContentType != "" {
+		n++
+	}
+	if resp.MatchBody != "" {
+		n++
+	}
+	if resp.MatchJSON != "" {
+		n++
+	}
+	if resp.Match != nil {
+		n++
+	}
+
+	return n
+}
+
+// matchCookies : returns true if every cookie of MatchCookies is present in given request
+func (resp *Response) matchCookies(r *http.Request) bool {
+	for name, value := range resp.MatchCookies {
+		cookie, err := r.Cookie(name)
+		if err != nil || cookie.Value != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchHost : returns true if given host matches MatchHost
+func (resp *Response) matchHost(host string) bool {
+	if resp.MatchHost == "" {
+		return true
+	}
+
+	if !strings.Contains(resp.MatchHost, ":") {
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+	}
+
+	return strings.EqualFold(resp.MatchHost, host)
+}
+
+// matchQuery : returns true if every value of MatchQuery matches a value present in given query
+func (resp *Response) matchQuery(query url.Values) bool {
+	for k, patterns := range resp.MatchQuery {
+		for _, pattern := range patterns {
+			if !containsGlob(query[k], pattern) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// containsGlob : returns true if any of values matches given pattern
+func containsGlob(values []string, pattern string) bool {
+	for _, v := range values {
+		if matchGlob(pattern, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchGlob : returns true if s matches pattern, where "*" matches any sequence of characters
+func matchGlob(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+
+	return len(s) >= len(last) && strings.HasSuffix(s, last)
+}
+
+// matchHeaders : returns true if every value of MatchHeaders is present in given header
+func (resp *Response) matchHeaders(header http.Header) bool {
+	for k, values := range resp.MatchHeaders {
+		actual := header[http.CanonicalHeaderKey(k)]
+		for _, v := range values {
+			if !containsString(actual, v) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Use : add middleware wrapping every request including unknown requests.
+// Middlewares are applied in the order they are added, the first one being the outermost.
+func (server *Server) Use(mw func(http.Handler) http.Handler) *Server {
+	server.middlewaresMu.Lock()
+	defer server.middlewaresMu.Unlock()
+
+	server.middlewares = append(server.middlewares, mw)
+	return server
+}
+
+// serveHTTP : handles request through middlewares
+func (server *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	server.middlewaresMu.RLock()
+	middlewares := server.middlewares
+	server.middlewaresMu.RUnlock()
+
+	if server.keepAliveDisabled() {
+		w.Header().Set("Connection", "close")
+	}
+
+	var handler http.Handler = http.HandlerFunc(server.handleRequest)
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+func (server *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
+	method := r.Method
+	path := r.URL.Path
+
+	if server.ContextFunc != nil {
+		r = r.WithContext(server.ContextFunc(r.Context(), r))
+	}
+
+	start := time.Now()
+	var key string
+	defer func() {
+		server.recordDuration(key, time.Since(start))
+	}()
+
+	if l := server.getFileLogger(); l != nil {
+		sw := &statusWriter{ResponseWriter: w}
+		w = sw
+		defer func() {
+			l.Printf("%s %s -> %d (%s)", method, path, sw.statusCode(), time.Since(start))
+		}()
+	}
+
+	if server.MaxBodySize > 0 {
+		if r.ContentLength > server.MaxBodySize {
+			// reject before reading the body, so that clients sending "Expect: 100-continue" do not upload it.
+			// The unread body is left on the connection, so close it instead of reusing it.
+			server.infof("request body too large : %s %s", method, path)
+			w.Header().Set("Connection", "close")
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, server.MaxBodySize)
+	}
+
+	// recordRequest reads the whole request body, so the connection can be reused
+	// even if neither matching nor the handler reads it.
+	// Reading the body also sends "100 Continue" to clients sending "Expect: 100-continue".
+	if err := server.recordRequest(r); err != nil {
+		if isBodyTooLarge(err) {
+			server.infof("request body too large : %s %s", method, path)
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		server.warnf("failed to read request body : %s %s -> %+v", method, path, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var matched bool
+	if idempotencyKey := server.idempotencyKey(r); idempotencyKey != "" {
+		entry, owner, err := server.awaitIdempotent(r.Context(), idempotencyKey)
+		if err != nil {
+			server.infof("request cancelled : %s %s", method, path)
+			return
+		}
+		if entry != nil && !owner {
+			server.infof("duplicate request : %s %s", method, path)
+			server.countReceived()
+			entry.replay(w)
+			return
+		}
+
+		if owner {
+			sw := newCapturingWriter(w)
+			w = sw
+			defer func() {
+				server.storeResponse(idempotencyKey, entry, sw, matched)
+			}()
+		}
+	}
+
+	resp := server.findResponse(r)
+	for resp != nil {
+		// rejected requests are not counted as served, and do not advance the sequence
+		if server.reject(w, r, server.currentInSequence(resp)) {
+			server.countReceived()
+			return
+		}
+		if retryAfter, ok := server.checkRateLimit(resp); !ok {
+			server.infof("rate limited : %s %s", method, path)
+			server.countReceived()
+			writeTooManyRequests(w, retryAfter)
+			return
+		}
+		if !resp.Once || server.consume(resp) {
+			break
+		}
+
+		// served to another request concurrently
+		server.releaseRateLimit(resp)
+		resp = server.findResponse(r)
+	}
+	server.countRequest(r, resp)
+	if resp != nil {
+		key = callKey(resp.Method, resp.Path)
+		matched = true
+	}
+	resp = server.nextInSequence(resp)
+	if resp != nil && server.OnMatch != nil {
+		server.OnMatch(r, resp)
+	}
+
+	// not found
+	if resp == nil {
+		server.warnf("unknown request: %s %s (query: %q)", method, path, r.URL.RawQuery)
+		if strings.EqualFold(method, "OPTIONS") && server.autoOptionsEnabled() {
+			if allowed := server.allowedMethods(path); len(allowed) > 0 {
+				w.Header().Set("Allow", strings.Join(append(allowed, "OPTIONS"), ", "))
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+		}
+
+		if server.UnknownRequestHandler != nil {
+			server.UnknownRequestHandler(w, r)
+			return
+		}
+
+		if resp := server.defaultResponse(); resp != nil {
+			if !server.reject(w, r, resp) {
+				server.serveResponse(w, r, resp)
+			}
+			return
+		}
+
+		if server.handleProxy(w, r) {
+			return
+		}
+
+		if allowed := server.allowedMethods(path); len(allowed) > 0 && !containsString(allowed, strings.ToUpper(method)) {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	if server.Before != nil {
+		resp = resp.clone()
+		server.Before(r, resp)
+		resp = compileResponse(*resp)
+	}
+
+	server.serveResponse(w, r, resp)
+}
+
+// isBodyTooLarge : returns true if given error is returned by http.MaxBytesReader.
+// The error is compared by its message, since http.MaxBytesError is not available until Go 1.19
+// and this package supports older versions.
+func isBodyTooLarge(err error) bool {
+	return err.Error() == "http: request body too large"
+}
+
+// reject : writes 401 Unauthorized or 400 Bad Request and returns true
+// if given request lacks basic auth credentials or violates RequestSchema of given response
+func (server *Server) reject(w http.ResponseWriter, r *http.Request, resp *Response) bool {
+	if !resp.authorized(r) {
+		server.infof("unauthorized : %s %s", r.Method, r.URL.Path)
+		w.Header().Set("WWW-Authenticate", `Basic realm="httpmocker"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return true
+	}
+
+	if resp.requestSchema != nil {
+		body, _ := bufferBody(r)
+		if errs := resp.requestSchema.validateJSON(body); len(errs) > 0 {
+			server.infof("invalid request : %s %s -> %s", r.Method, r.URL.Path, strings.Join(errs, ", "))
+			writeSchemaErrors(w, errs)
+			return true
+		}
+	}
+
+	return false
+}
+
+// serveResponse : writes given mock response
+func (server *Server) serveResponse(w http.ResponseWriter, r *http.Request, resp *Response) {
+	method := r.Method
+	path := r.URL.Path
+
+	if resp.pathRegexp != nil {
+		r = r.WithContext(context.WithValue(r.Context(), PathParamsKey, resp.pathParams(path)))
+	}
+	if resp.pathPrefix != "" {
+		r = r.WithContext(context.WithValue(r.Context(), PathSuffixKey, strings.TrimPrefix(path, resp.pathPrefix)))
+	}
+
+	if resp.Inspect != nil {
+		resp.Inspect(r)
+	}
+
+	if !sleep(r.Context(), server.delay(resp)) {
+		server.infof("request cancelled : %s %s", method, path)
+		return
+	}
+
+	if resp.Fault != FaultNone {
+		server.infof("fault : %s %s -> %d", method, path, resp.Fault)
+		server.injectFault(w, resp.Fault)
+		return
+	}
+
+	// Send response.
+
+	if len(resp.variants) > 0 {
+		w.Header().Add("Vary", "Accept")
+		if v := resp.variant(r); v != nil {
+			resp = resp.withVariant(v)
+		}
+	}
+
+	for _, k := range resp.ReflectHeaders {
+		if values := r.Header[http.CanonicalHeaderKey(k)]; len(values) > 0 {
+			w.Header()[http.CanonicalHeaderKey(k)] = append([]string(nil), values...)
+		}
+	}
+
+	if resp.Handler != nil {
+		// if Handler is set, delegate response
+		if server.DefaultContentType != "" {
+			w.Header().Set("Content-Type", server.DefaultContentType)
+		}
+		resp.Handler(w, r)
+		return
+	}
+
+	body, err := resp.body(r)
+	if err != nil {
+		server.warnf("failed to build response body : %s %s -> %+v", method, path, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	gzipFile := resp.isGzipFile()
+	raw := body
+	if gzipFile {
+		if body, err = gunzipBody(raw); err != nil {
+			server.warnf("failed to decompress response body : %s %s -> %+v", method, path, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	code := server.code(resp)
+	if code == http.StatusNoContent || code == http.StatusNotModified {
+		// these responses must not have a body
+		body = nil
+	}
+	empty := len(body) == 0 && len(resp.Chunks) == 0
+
+	contentType := resp.contentType()
+	if contentType == "" {
+		contentType = server.DefaultContentType
+	}
+	if contentType == "" && len(body) > 0 {
+		// sniff before compression
+		contentType = http.DetectContentType(body)
+	}
+	if empty {
+		contentType = ""
+	}
+
+	gzipped := (resp.Gzip || gzipFile) && !empty && len(resp.Chunks) == 0 && acceptsGzip(r)
+	if gzipped && gzipFile {
+		body = raw
+	} else if gzipped {
+		if body, err = gzipBody(body); err != nil {
+			server.warnf("failed to compress response body : %s %s -> %+v", method, path, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	header := w.Header()
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	for k, values := range resp.Headers {
+		// Headers override default headers such as Content-Type
+		header.Del(k)
+		for _, v := range values {
+			header.Add(k, v)
+		}
+	}
+	for _, cookie := range resp.Cookies {
+		http.SetCookie(w, cookie)
+	}
+	if resp.Gzip || gzipFile {
+		header.Add("Vary", "Accept-Encoding")
+	}
+	if gzipped {
+		header.Set("Content-Encoding", "gzip")
+	}
+
+	if resp.Status != "" && server.writeRawResponse(w, r, resp, code, body) {
+		server.debugf("handler : %s %s (query: %q, matched query: %q) -> %+v", method, path, r.URL.RawQuery, resp.Query, resp)
+		return
+	}
+
+	for k := range resp.Trailers {
+		header.Add("Trailer", k)
+	}
+	if !empty && len(resp.Chunks) == 0 && len(resp.Trailers) == 0 && header.Get("Content-Length") == "" {
+		// set explicitly so that large bodies are not chunked, and HEAD responses carry it
+		header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+	w.WriteHeader(code)
+
+	switch {
+	case len(resp.Chunks) > 0:
+		server.writeChunks(w, r, resp)
+	case resp.Trickle > 0:
+		server.writeTrickle(w, r, body, resp)
+	case !empty:
+		w.Write(body)
+	}
+
+	for k, values := range resp.Trailers {
+		for _, v := range values {
+			header.Add(k, v)
+		}
+	}
+
+	server.debugf("handler : %s %s (query: %q, matched query: %q) -> %+v", method, path, r.URL.RawQuery, resp.Query, resp)
+}
+
+// clone : returns a copy of the response whose headers and cookies can be modified without affecting the original
+func (resp *Response) clone() *Response {
+	r := *resp
+	r.Headers = cloneHeader(resp.Headers)
+	r.Trailers = cloneHeader(resp.Trailers)
+	r.Cookies = append([]*http.Cookie(nil), resp.Cookies...)
+	if resp.BodyBytes != nil {
+		r.BodyBytes = append([]byte{}, resp.BodyBytes...)
+	}
+	return &r
+}
+
+// RequireBasicAuth : returns a copy of the response which requires given basic auth credentials
+func (resp Response) RequireBasicAuth(user, pass string) Response {
+	resp.BasicAuthUser = user
+	resp.BasicAuthPassword = pass
+	return resp
+}
+
+// authorized : returns true if basic auth is not required or given request has the credentials
+func (resp *Response) authorized(r *http.Request) bool {
+	if resp.BasicAuthUser == "" {
+		return true
+	}
+
+	user, pass, ok := r.BasicAuth()
+	return ok && user == resp.BasicAuthUser && pass == resp.BasicAuthPassword
+}
+
+// body : returns response body
+func (resp *Response) body(r *http.Request) ([]byte, error) {
+	if resp.JSONBody != nil {
+		return json.Marshal(resp.JSONBody)
+	}
+
+	if resp.bodyTemplate != nil {
+		return resp.executeTemplate(r)
+	}
+
+	if resp.BodyBytes != nil {
+		return resp.BodyBytes, nil
+	}
+
+	if resp.Body == "" && resp.BodyFile != "" {
+		return ioutil.ReadFile(resp.BodyFile)
+	}
+
+	return []byte(resp.Body), nil
+}
+
+// isGzipFile : returns true if response body is read from BodyFile ending with ".gz"
+func (resp *Response) isGzipFile() bool {
+	return resp.JSONBody == nil && resp.bodyTemplate == nil && resp.BodyBytes == nil && resp.Body == "" &&
+		strings.HasSuffix(resp.BodyFile, ".gz")
+}
+
+// contentType : returns ContentType, or application/json if JSONBody is set
+func (resp *Response) contentType() string {
+	if resp.ContentType == "" && resp.JSONBody != nil {
+		return "application/json"
+	}
+
+	return resp.ContentType
+}
+
+func (server *Server) countRequest(r *http.Request, resp *Response) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.totalRequests++
+	if resp == nil {
+		server.unmatched = append(server.unmatched, callKey(r.Method, r.URL.Path))
+		return
+	}
+
+	if server.callCounts == nil {
+		server.callCounts = map[string]int{}
+	}
+	server.callCounts[callKey(resp.Method, resp.Path)]++
+}
+
+// countReceived : counts a request not served by a mock response, such as rejected or replayed ones, in TotalRequests only
+func (server *Server) countReceived() {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.totalRequests++
+}
+
+func callKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+// CallCount : returns how many times the response registered with given method and path was served.
+// Requests rejected by rate limit, basic auth or RequestSchema are not counted.
+func (server *Server) CallCount(method, path string) int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	return server.callCounts[callKey(method, path)]
+}
+
+// TotalRequests : returns the number of all received requests including unknown requests
+func (server *Server) TotalRequests() int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	return server.totalRequests
+}
+
+// durationStat : total duration and number of requests served by a response
+type durationStat struct {
+	total time.Duration
+	count int
+}
+
+// recordDuration : records how long it took to handle a request. key is empty for unknown requests.
+func (server *Server) recordDuration(key string, d time.Duration) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.lastDuration = d
+	if key == "" {
+		return
+	}
+
+	if server.durations == nil {
+		server.durations = map[string]*durationStat{}
+	}
+	stat := server.durations[key]
+	if stat == nil {
+		stat = &durationStat{}
+		server.durations[key] = stat
+	}
+	stat.total += d
+	stat.count++
+}
+
+// LastDuration : returns how long it took to handle the last request including Delay
+func (server *Server) LastDuration() time.Duration {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	return server.lastDuration
+}
+
+// Durations : returns average durations to handle requests, keyed by method and path of responses such as "GET /hello"
+func (server *Server) Durations() map[string]time.Duration {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	durations := make(map[string]time.Duration, len(server.durations))
+	for key, stat := range server.durations {
+		durations[key] = stat.total / time.Duration(stat.count)
+	}
+
+	return durations
+}
+
+// sleep : waits for given duration, returns false if ctx is done before that
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (server *Server) logf(msg string, args ...interface{}) {
+	server.logToFile(msg, args...)
+	if server.Logger != nil {
+		server.Logger.Logf(msg, args...)
+	}
+}
+
+func (server *Server) debugf(msg string, args ...interface{}) {
+	if l, ok := server.Logger.(LevelLogger); ok {
+		server.logToFile(msg, args...)
+		l.Debugf(msg, args...)
+		return
+	}
+	server.logf(msg, args...)
+}
+
+func (server *Server) infof(msg string, args ...interface{}) {
+	if l, ok := server.Logger.(LevelLogger); ok {
+		server.logToFile(msg, args...)
+		l.Infof(msg, args...)
+		return
+	}
+	server.logf(msg, args...)
+}
+
+func (server *Server) warnf(msg string, args ...interface{}) {
+	if l, ok := server.Logger.(LevelLogger); ok {
+		server.logToFile(msg, args...)
+		l.Warnf(msg, args...)
+		return
+	}
+	server.logf(msg, args...)
+}
+
+// Handler : returns http.Handler serving mock responses, which can be mounted on another server
+func (server *Server) Handler() http.Handler {
+	return http.HandlerFunc(server.serveHTTP)
+}
+
+// Start : start up mock server
+func (server *Server) Start() *Server {
+	httptestserver := httptest.NewServer(server.Handler())
+	server.Server = httptestserver
+	server.URL = httptestserver.URL
+	return server
+}
+
+// StartTLS : start up mock server with TLS
+func (server *Server) StartTLS() *Server {
+	httptestserver := httptest.NewTLSServer(server.Handler())
+	server.Server = httptestserver
+	server.URL = httptestserver.URL
+	return server
+}
+
+// StartHTTP2 : start up mock server with TLS and HTTP/2 enabled
+func (server *Server) StartHTTP2() *Server {
+	httptestserver := httptest.NewUnstartedServer(server.Handler())
+	httptestserver.EnableHTTP2 = true
+	httptestserver.StartTLS()
+	server.Server = httptestserver
+	server.URL = httptestserver.URL
+	return server
+}
+
+// Client : returns http client configured to trust the certificate of mock server, and to speak HTTP/2 if enabled
+func (server *Server) Client() *http.Client {
+	if server.Server == nil {
+		return http.DefaultClient
+	}
+
+	return server.Server.Client()
+}
+
+// Addr : returns the address such as "127.0.0.1:8080" mock server listens on, or empty if not started
+func (server *Server) Addr() string {
+	if server.Server == nil || server.Server.Listener == nil {
+		return ""
+	}
+
+	return server.Server.Listener.Addr().String()
+}
+
+// Port : returns the port mock server listens on, or zero if not started
+func (server *Server) Port() int {
+	if server.Server == nil || server.Server.Listener == nil {
+		return 0
+	}
+
+	if addr, ok := server.Server.Listener.Addr().(*net.TCPAddr); ok {
+		return addr.Port
+	}
+
+	return 0
+}
+
+// New : returns mock server with given mock requests without starting it.
+// Callers must call Start, StartTLS or StartHTTP2 themselves.
+func New(responses ...Response) *Server {
+	server := Server{}
+	server.Responses = map[string]map[string][]*Response{}
+	server.AddResponses(responses...)
+
+	return &server
+}
+
+// Launch : launch mock server with given mock requests
+func Launch(responses ...Response) *Server {
+	return New(responses...).Start()
+}
+
+// LaunchTLS : launch mock server over HTTPS with given mock requests.
+// Use Client to make requests without TLS verification errors.
+func LaunchTLS(responses ...Response) *Server {
+	return New(responses...).StartTLS()
+}
+
+// LaunchHTTP2 : launch mock server over HTTPS with HTTP/2 enabled.
+// Use Client to make requests over HTTP/2.
+func LaunchHTTP2(responses ...Response) *Server {
+	return New(responses...).StartHTTP2()
+}
+
+// LaunchOnAddr : launch mock server listening on given address such as "127.0.0.1:8080".
+// It returns an error if the address is already in use.
+func LaunchOnAddr(addr string, responses ...Response) (*Server, error) {
+	return LaunchWithOptions(WithAddr(addr), WithResponses(responses...))
 }
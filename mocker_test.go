@@ -1,23 +1,37 @@
 package httpmocker
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
-func TestMockServer(t *testing.T) {
-	drainBody := func(t *testing.T, resp *http.Response) string {
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			t.Fatalf("unexpected error : %+v", err)
-		}
-
-		return string(body)
+// drainBody : reads whole response body
+func drainBody(t *testing.T, resp *http.Response) string {
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("unexpected error : %+v", err)
 	}
 
+	return string(body)
+}
+
+func TestMockServer(t *testing.T) {
 	t.Run("Simple mocking", func(t *testing.T) {
 		server := Launch(
 			Response{
@@ -176,24 +190,3274 @@ func TestMockServer(t *testing.T) {
 		server.Logger = &logger
 		defer server.Close()
 
-		url := fmt.Sprintf("%s/hello", server.URL)
+		url := fmt.Sprintf("%s/hello?dummy=1", server.URL)
 		_, err := http.Get(url)
 		if err != nil {
 			t.Fatalf("unexpected error : %+v", err)
 		}
 
-		if logger.msg != "handler : %s %s -> %+v" {
+		if logger.msg != "handler : %s %s (query: %q, matched query: %q) -> %+v" {
 			t.Errorf("unexpected message is passed to logger : actual : %s", logger.msg)
 		}
+
+		if len(logger.args) != 5 || logger.args[2] != "dummy=1" || logger.args[3] != "" {
+			t.Errorf("query string and matched query should be passed to logger : actual : %v", logger.args)
+		}
 	})
-}
 
-type customLogger struct {
-	msg  string
-	args []interface{}
-}
+	t.Run("match headers", func(t *testing.T) {
+		server := Launch(
+			Response{
+				Method: "GET",
+				Path:   "/hello",
+				Code:   http.StatusOK,
+				Body:   "hello, world",
+			},
+			Response{
+				Method:       "GET",
+				Path:         "/hello",
+				Code:         http.StatusOK,
+				Body:         "hello, json",
+				MatchHeaders: map[string][]string{"Accept": []string{"application/json"}},
+			},
+			Response{
+				Method: "GET",
+				Path:   "/hello",
+				Code:   http.StatusOK,
+				Body:   "hello, authorized json",
+				MatchHeaders: map[string][]string{
+					"Accept":        []string{"application/json"},
+					"Authorization": []string{"Bearer token"},
+				},
+			},
+		)
+		server.Logger = t
+		defer server.Close()
 
-func (l *customLogger) Logf(msg string, args ...interface{}) {
-	l.msg = msg
-	l.args = args
+		get := func(headers map[string]string) string {
+			req, err := http.NewRequest("GET", fmt.Sprintf("%s/hello", server.URL), nil)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			for k, v := range headers {
+				req.Header.Set(k, v)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			return drainBody(t, resp)
+		}
+
+		// if no headers are matched, mock server should return the response without MatchHeaders
+		body := get(nil)
+		if body != "hello, world" {
+			t.Errorf("response body should be \"hello, world\": actual %s", body)
+		}
+
+		body = get(map[string]string{"Accept": "application/json"})
+		if body != "hello, json" {
+			t.Errorf("response body should be \"hello, json\": actual %s", body)
+		}
+
+		// the response with the most matching headers should win
+		body = get(map[string]string{"Accept": "application/json", "Authorization": "Bearer token"})
+		if body != "hello, authorized json" {
+			t.Errorf("response body should be \"hello, authorized json\": actual %s", body)
+		}
+	})
+
+	t.Run("regex path", func(t *testing.T) {
+		server := Launch(
+			Response{
+				Method:    "GET",
+				Path:      `/users/\d+/orders/\d+`,
+				PathRegex: true,
+				Code:      http.StatusOK,
+				Body:      "order",
+			},
+			Response{
+				Method: "GET",
+				Path:   "/users/1/orders/1",
+				Code:   http.StatusOK,
+				Body:   "first order",
+			},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		url := fmt.Sprintf("%s/users/123/orders/456", server.URL)
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		body := drainBody(t, resp)
+		if body != "order" {
+			t.Errorf("response body should be \"order\": actual %s", body)
+		}
+
+		// exact match should take priority over regex match
+		url = fmt.Sprintf("%s/users/1/orders/1", server.URL)
+		resp, err = http.Get(url)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		body = drainBody(t, resp)
+		if body != "first order" {
+			t.Errorf("response body should be \"first order\": actual %s", body)
+		}
+
+		// pattern should match the whole path
+		url = fmt.Sprintf("%s/users/123/orders/456/items", server.URL)
+		resp, err = http.Get(url)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		body = drainBody(t, resp)
+		if body != "" {
+			t.Errorf("response body should be empty: actual %s", body)
+		}
+	})
+
+	t.Run("path params", func(t *testing.T) {
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			params := PathParams(r)
+			fmt.Fprintf(w, "user %s, order %s", params["id"], params["order"])
+		}
+		server := Launch(
+			Response{
+				Method:    "GET",
+				Path:      `/users/{id}/orders/(?P<order>\d+)`,
+				PathRegex: true,
+				Handler:   handler,
+			},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		url := fmt.Sprintf("%s/users/alice/orders/456", server.URL)
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		body := drainBody(t, resp)
+		if body != "user alice, order 456" {
+			t.Errorf("response body should be \"user alice, order 456\": actual %s", body)
+		}
+	})
+
+	t.Run("with delay", func(t *testing.T) {
+		server := Launch(
+			Response{
+				Method: "GET",
+				Path:   "/slow",
+				Code:   http.StatusOK,
+				Body:   "slow",
+				Delay:  100 * time.Millisecond,
+			},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		url := fmt.Sprintf("%s/slow", server.URL)
+		start := time.Now()
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		drainBody(t, resp)
+
+		if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+			t.Errorf("response should be delayed at least 100ms: actual %s", elapsed)
+		}
+
+		// client timeout should be shorter than delay
+		client := &http.Client{Timeout: 10 * time.Millisecond}
+		_, err = client.Get(url)
+		if err == nil {
+			t.Errorf("request should be timed out")
+		}
+	})
+
+	t.Run("with body file", func(t *testing.T) {
+		server := Launch(
+			Response{
+				Method:      "GET",
+				Path:        "/hello",
+				Code:        http.StatusOK,
+				ContentType: "application/json",
+				BodyFile:    "testdata/hello.json",
+			},
+			Response{
+				Method:   "GET",
+				Path:     "/missing",
+				Code:     http.StatusOK,
+				BodyFile: "testdata/missing.json",
+			},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		url := fmt.Sprintf("%s/hello", server.URL)
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		body := drainBody(t, resp)
+		if body != "{\"message\": \"hello, world\"}\n" {
+			t.Errorf("response body should be the content of testdata/hello.json: actual %s", body)
+		}
+
+		// if the file can't be read, mock server should return 500
+		url = fmt.Sprintf("%s/missing", server.URL)
+		resp, err = http.Get(url)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if resp.StatusCode != http.StatusInternalServerError {
+			t.Errorf("status code should be 500 Internal Server Error: actual %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("call count", func(t *testing.T) {
+		server := Launch()
+		server.Add("GET", "/hello", http.StatusOK, "hello, world")
+		server.Logger = t
+		defer server.Close()
+
+		for i := 0; i < 3; i++ {
+			resp, err := http.Get(fmt.Sprintf("%s/hello", server.URL))
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			drainBody(t, resp)
+		}
+
+		resp, err := http.Get(fmt.Sprintf("%s/unknown", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		drainBody(t, resp)
+
+		if n := server.CallCount("GET", "/hello"); n != 3 {
+			t.Errorf("call count should be 3: actual %d", n)
+		}
+
+		if n := server.CallCount("POST", "/hello"); n != 0 {
+			t.Errorf("call count should be 0: actual %d", n)
+		}
+
+		if n := server.TotalRequests(); n != 4 {
+			t.Errorf("total requests should be 4: actual %d", n)
+		}
+	})
+
+	t.Run("received requests", func(t *testing.T) {
+		server := Launch(
+			Response{
+				Method: "POST",
+				Path:   "/sushi",
+				Handler: func(w http.ResponseWriter, r *http.Request) {
+					// request body should still be readable from handlers
+					io.Copy(w, r.Body)
+				},
+			},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		url := fmt.Sprintf("%s/sushi?count=2", server.URL)
+		resp, err := http.Post(url, "text/plain", strings.NewReader("maguro"))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		body := drainBody(t, resp)
+		if body != "maguro" {
+			t.Errorf("response body should be \"maguro\": actual %s", body)
+		}
+
+		requests := server.ReceivedRequests()
+		if len(requests) != 1 {
+			t.Fatalf("received requests should be 1: actual %d", len(requests))
+		}
+
+		req := requests[0]
+		if req.Method != "POST" || req.Path != "/sushi" || req.Query != "count=2" {
+			t.Errorf("unexpected request is recorded : %+v", req)
+		}
+
+		if ctype := req.Header.Get("Content-Type"); ctype != "text/plain" {
+			t.Errorf("recorded Content-Type should be text/plain: actual %s", ctype)
+		}
+
+		if string(req.Body) != "maguro" {
+			t.Errorf("recorded body should be \"maguro\": actual %s", string(req.Body))
+		}
+
+		server.Reset()
+		if n := len(server.ReceivedRequests()); n != 0 {
+			t.Errorf("received requests should be cleared: actual %d", n)
+		}
+	})
+
+	t.Run("add responses concurrently", func(t *testing.T) {
+		server := Launch()
+		server.Logger = t
+		defer server.Close()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+
+				path := fmt.Sprintf("/hello/%d", i)
+				server.Add("GET", path, http.StatusOK, "hello, world").AddEmptyResponse("POST", path, http.StatusCreated)
+
+				resp, err := http.Get(server.URL + path)
+				if err != nil {
+					t.Errorf("unexpected error : %+v", err)
+					return
+				}
+				resp.Body.Close()
+			}(i)
+		}
+		wg.Wait()
+
+		if n := server.TotalRequests(); n != 10 {
+			t.Errorf("total requests should be 10: actual %d", n)
+		}
+	})
+
+	t.Run("with fault", func(t *testing.T) {
+		server := Launch(
+			Response{
+				Method: "GET",
+				Path:   "/close",
+				Code:   http.StatusOK,
+				Body:   "never sent",
+				Fault:  FaultCloseConnection,
+			},
+			Response{
+				Method: "GET",
+				Path:   "/empty",
+				Code:   http.StatusOK,
+				Body:   "never sent",
+				Fault:  FaultEmptyResponse,
+			},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		for _, path := range []string{"/close", "/empty"} {
+			resp, err := http.Get(server.URL + path)
+			if err == nil {
+				resp.Body.Close()
+				t.Errorf("request to %s should fail: actual %s", path, resp.Status)
+			}
+		}
+	})
+
+	t.Run("with TLS", func(t *testing.T) {
+		server := LaunchTLS().Add("GET", "/hello", http.StatusOK, "hello, world")
+		server.Logger = t
+		defer server.Close()
+
+		if !strings.HasPrefix(server.URL, "https://") {
+			t.Errorf("URL should start with https:// : actual %s", server.URL)
+		}
+
+		url := fmt.Sprintf("%s/hello", server.URL)
+		resp, err := server.Client().Get(url)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		body := drainBody(t, resp)
+		if body != "hello, world" {
+			t.Errorf("response body should be \"hello, world\": actual %s", body)
+		}
+	})
+
+	t.Run("match body", func(t *testing.T) {
+		server := Launch(
+			Response{
+				Method: "POST",
+				Path:   "/search",
+				Code:   http.StatusOK,
+				Body:   "no results",
+			},
+			Response{
+				Method:    "POST",
+				Path:      "/search",
+				Code:      http.StatusOK,
+				Body:      "sushi results",
+				MatchBody: `{"q":"sushi"}`,
+			},
+			Response{
+				Method:            "POST",
+				Path:              "/search",
+				Code:              http.StatusOK,
+				Body:              "ramen results",
+				MatchBody:         "ramen",
+				MatchBodyContains: true,
+				Handler: func(w http.ResponseWriter, r *http.Request) {
+					// request body should be restored for handlers
+					body, _ := ioutil.ReadAll(r.Body)
+					fmt.Fprintf(w, "results for %s", body)
+				},
+			},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		post := func(body string) string {
+			resp, err := http.Post(fmt.Sprintf("%s/search", server.URL), "application/json", strings.NewReader(body))
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			return drainBody(t, resp)
+		}
+
+		if body := post(`{"q":"sushi"}`); body != "sushi results" {
+			t.Errorf("response body should be \"sushi results\": actual %s", body)
+		}
+
+		if body := post(`{"q":"ramen"}`); body != `results for {"q":"ramen"}` {
+			t.Errorf("response body should be \"results for {\"q\":\"ramen\"}\": actual %s", body)
+		}
+
+		if body := post(`{"q":"tempura"}`); body != "no results" {
+			t.Errorf("response body should be \"no results\": actual %s", body)
+		}
+	})
+
+	t.Run("match JSON", func(t *testing.T) {
+		server := Launch(
+			Response{
+				Method: "POST",
+				Path:   "/orders",
+				Code:   http.StatusBadRequest,
+				Body:   "unknown order",
+			},
+			Response{
+				Method:    "POST",
+				Path:      "/orders",
+				Code:      http.StatusCreated,
+				Body:      "sushi ordered",
+				MatchJSON: `{"item": {"name": "sushi"}, "tags": ["fresh"]}`,
+			},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		post := func(body string) string {
+			resp, err := http.Post(fmt.Sprintf("%s/orders", server.URL), "application/json", strings.NewReader(body))
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			return drainBody(t, resp)
+		}
+
+		// request should match regardless of key order, whitespace and extra fields
+		body := post(`{"tags":["spicy","fresh"],"count":2,"item":{"price":100,"name":"sushi"}}`)
+		if body != "sushi ordered" {
+			t.Errorf("response body should be \"sushi ordered\": actual %s", body)
+		}
+
+		body = post(`{"item":{"name":"ramen"},"tags":["fresh"]}`)
+		if body != "unknown order" {
+			t.Errorf("response body should be \"unknown order\": actual %s", body)
+		}
+
+		// invalid JSON should not match
+		body = post(`{"item":`)
+		if body != "unknown order" {
+			t.Errorf("response body should be \"unknown order\": actual %s", body)
+		}
+
+		// null should match only JSON null
+		server.AddResponses(Response{Method: "POST", Path: "/orders", Code: http.StatusOK, Body: "null order", MatchJSON: "null"})
+		if body := post(""); body != "unknown order" {
+			t.Errorf("empty body should not match null: actual %s", body)
+		}
+		if body := post("null"); body != "null order" {
+			t.Errorf("response body should be \"null order\": actual %s", body)
+		}
+	})
+
+	t.Run("with JSON body", func(t *testing.T) {
+		server := Launch(
+			Response{
+				Method:   "GET",
+				Path:     "/sushi",
+				Code:     http.StatusOK,
+				JSONBody: map[string]interface{}{"name": "maguro", "price": 100},
+			},
+			Response{
+				Method:      "GET",
+				Path:        "/custom",
+				Code:        http.StatusOK,
+				ContentType: "application/vnd.api+json",
+				JSONBody:    []string{"maguro"},
+			},
+			Response{
+				Method:   "GET",
+				Path:     "/invalid",
+				Code:     http.StatusOK,
+				JSONBody: map[string]interface{}{"ch": make(chan int)},
+			},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		resp, err := http.Get(fmt.Sprintf("%s/sushi", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if ctype := resp.Header.Get("Content-Type"); ctype != "application/json" {
+			t.Errorf("ContentType should be application/json: actual %s", ctype)
+		}
+
+		body := drainBody(t, resp)
+		if body != `{"name":"maguro","price":100}` {
+			t.Errorf("response body should be marshaled JSON: actual %s", body)
+		}
+
+		// ContentType should override the default
+		resp, err = http.Get(fmt.Sprintf("%s/custom", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if ctype := resp.Header.Get("Content-Type"); ctype != "application/vnd.api+json" {
+			t.Errorf("ContentType should be application/vnd.api+json: actual %s", ctype)
+		}
+		drainBody(t, resp)
+
+		// marshal errors should produce 500
+		resp, err = http.Get(fmt.Sprintf("%s/invalid", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if resp.StatusCode != http.StatusInternalServerError {
+			t.Errorf("status code should be 500 Internal Server Error: actual %d", resp.StatusCode)
+		}
+
+		if body := drainBody(t, resp); body != "" {
+			t.Errorf("response body should be empty: actual %s", body)
+		}
+	})
+
+	t.Run("with body template", func(t *testing.T) {
+		server := Launch(
+			Response{
+				Method:       "GET",
+				Path:         "/users/{id}",
+				PathRegex:    true,
+				Code:         http.StatusOK,
+				BodyTemplate: `user {{.PathParams.id}}, sort {{.Query.Get "sort"}}, token {{.Header.Get "X-Token"}}`,
+			},
+			Response{
+				Method:       "GET",
+				Path:         "/invalid",
+				Code:         http.StatusOK,
+				BodyTemplate: `{{.PathParams.id}}`,
+			},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		req, err := http.NewRequest("GET", fmt.Sprintf("%s/users/123?sort=desc", server.URL), nil)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		req.Header.Set("X-Token", "secret")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		body := drainBody(t, resp)
+		if body != "user 123, sort desc, token secret" {
+			t.Errorf("response body should be \"user 123, sort desc, token secret\": actual %s", body)
+		}
+
+		// execution errors should produce 500
+		resp, err = http.Get(fmt.Sprintf("%s/invalid", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if resp.StatusCode != http.StatusInternalServerError {
+			t.Errorf("status code should be 500 Internal Server Error: actual %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("remove responses", func(t *testing.T) {
+		server := Launch(
+			Response{Method: "GET", Path: "/hello", Code: http.StatusOK, Body: "hello, world"},
+			Response{Method: "GET", Path: "/hello", Query: "dummy=1", Code: http.StatusOK, Body: "hello, query"},
+			Response{Method: "GET", Path: "/sushi", Code: http.StatusOK, Body: "🍣"},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		get := func(path string) *http.Response {
+			resp, err := http.Get(server.URL + path)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			return resp
+		}
+
+		server.RemoveResponse("GET", "/hello", "dummy=1")
+		if body := drainBody(t, get("/hello?dummy=1")); body != "hello, world" {
+			t.Errorf("response body should be \"hello, world\": actual %s", body)
+		}
+
+		server.Remove("GET", "/sushi").Remove("GET", "/unknown").RemoveResponse("POST", "/unknown", "")
+		if body := drainBody(t, get("/sushi")); body != "" {
+			t.Errorf("response body should be empty: actual %s", body)
+		}
+
+		server.RemoveResponse("GET", "/hello", "")
+		if body := drainBody(t, get("/hello")); body != "" {
+			t.Errorf("response body should be empty: actual %s", body)
+		}
+	})
+
+	t.Run("clear responses", func(t *testing.T) {
+		server := Launch().Add("GET", "/hello", http.StatusOK, "hello, world")
+		server.Logger = t
+		defer server.Close()
+
+		url := server.URL
+		resp, err := http.Get(fmt.Sprintf("%s/hello", url))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		drainBody(t, resp)
+
+		server.Clear().Add("GET", "/sushi", http.StatusOK, "🍣")
+
+		if server.URL != url {
+			t.Errorf("URL should not be changed: expected %s, actual %s", url, server.URL)
+		}
+
+		if n := server.TotalRequests(); n != 0 {
+			t.Errorf("total requests should be cleared: actual %d", n)
+		}
+
+		resp, err = http.Get(fmt.Sprintf("%s/hello", url))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if body := drainBody(t, resp); body != "" {
+			t.Errorf("response body should be empty: actual %s", body)
+		}
+
+		resp, err = http.Get(fmt.Sprintf("%s/sushi", url))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if body := drainBody(t, resp); body != "🍣" {
+			t.Errorf("response body should be \"🍣\": actual %s", body)
+		}
+	})
+
+	t.Run("with gzip", func(t *testing.T) {
+		server := Launch(
+			Response{
+				Method: "GET",
+				Path:   "/hello",
+				Code:   http.StatusOK,
+				Body:   "hello, world",
+				Gzip:   true,
+			},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		// disable transparent decompression to inspect the raw response
+		client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+		get := func(acceptEncoding string) (*http.Response, []byte) {
+			req, err := http.NewRequest("GET", fmt.Sprintf("%s/hello", server.URL), nil)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			if acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", acceptEncoding)
+			}
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			defer resp.Body.Close()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			return resp, body
+		}
+
+		resp, body := get("deflate, gzip")
+		if enc := resp.Header.Get("Content-Encoding"); enc != "gzip" {
+			t.Errorf("Content-Encoding should be gzip: actual %s", enc)
+		}
+
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		decompressed, err := ioutil.ReadAll(gr)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if string(decompressed) != "hello, world" {
+			t.Errorf("decompressed body should be \"hello, world\": actual %s", string(decompressed))
+		}
+
+		// body should be sent uncompressed when the client doesn't accept gzip
+		for _, acceptEncoding := range []string{"", "gzip;q=0", "*, gzip;q=0", "gzip;q=0, *"} {
+			resp, body = get(acceptEncoding)
+			if enc := resp.Header.Get("Content-Encoding"); enc != "" {
+				t.Errorf("Content-Encoding should be empty: actual %s", enc)
+			}
+
+			if string(body) != "hello, world" {
+				t.Errorf("response body should be \"hello, world\": actual %s", string(body))
+			}
+		}
+	})
+
+	t.Run("with chunks", func(t *testing.T) {
+		server := Launch(
+			Response{
+				Method:     "GET",
+				Path:       "/stream",
+				Code:       http.StatusOK,
+				Chunks:     []string{"first\n", "second\n", "third\n"},
+				ChunkDelay: 50 * time.Millisecond,
+			},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		resp, err := http.Get(fmt.Sprintf("%s/stream", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		defer resp.Body.Close()
+
+		// each chunk should arrive separately
+		reader := bufio.NewReader(resp.Body)
+		start := time.Now()
+		for _, expected := range []string{"first\n", "second\n", "third\n"} {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			if line != expected {
+				t.Errorf("chunk should be %q: actual %q", expected, line)
+			}
+		}
+
+		if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+			t.Errorf("chunks should be delayed at least 100ms in total: actual %s", elapsed)
+		}
+	})
+
+	t.Run("case-insensitive method", func(t *testing.T) {
+		server := Launch().Add("GET", "/hello", http.StatusOK, "hello, world")
+		server.Logger = t
+		defer server.Close()
+
+		req, err := http.NewRequest("get", fmt.Sprintf("%s/hello", server.URL), nil)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if body := drainBody(t, resp); body != "hello, world" {
+			t.Errorf("response body should be \"hello, world\": actual %s", body)
+		}
+
+		if n := server.CallCount("get", "/hello"); n != 1 {
+			t.Errorf("call count should be 1: actual %d", n)
+		}
+	})
+
+	t.Run("wildcard path", func(t *testing.T) {
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "static %s", PathSuffix(r))
+		}
+		server := Launch(
+			Response{Method: "GET", Path: "/static/*", Handler: handler},
+			Response{Method: "GET", Path: "/static/img/**", Code: http.StatusOK, Body: "image"},
+			Response{Method: "GET", Path: "/static/index.html", Code: http.StatusOK, Body: "index"},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		get := func(path string) string {
+			resp, err := http.Get(server.URL + path)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			return drainBody(t, resp)
+		}
+
+		if body := get("/static/css/app.css"); body != "static css/app.css" {
+			t.Errorf("response body should be \"static css/app.css\": actual %s", body)
+		}
+
+		// longer prefix should win
+		if body := get("/static/img/logo.png"); body != "image" {
+			t.Errorf("response body should be \"image\": actual %s", body)
+		}
+
+		// exact match should win
+		if body := get("/static/index.html"); body != "index" {
+			t.Errorf("response body should be \"index\": actual %s", body)
+		}
+
+		if body := get("/assets/app.css"); body != "" {
+			t.Errorf("response body should be empty: actual %s", body)
+		}
+	})
+
+	t.Run("match query", func(t *testing.T) {
+		server := Launch(
+			Response{
+				Method: "GET",
+				Path:   "/search",
+				Code:   http.StatusOK,
+				Body:   "all",
+			},
+			Response{
+				Method:     "GET",
+				Path:       "/search",
+				Code:       http.StatusOK,
+				Body:       "sushi",
+				MatchQuery: url.Values{"q": []string{"sushi"}},
+			},
+			Response{
+				Method:     "GET",
+				Path:       "/search",
+				Code:       http.StatusOK,
+				Body:       "sushi page 2",
+				MatchQuery: url.Values{"q": []string{"sushi"}, "page": []string{"2"}},
+			},
+			Response{
+				Method: "GET",
+				Path:   "/search",
+				Query:  "page=2&q=sushi",
+				Code:   http.StatusOK,
+				Body:   "exact",
+			},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		get := func(query string) string {
+			resp, err := http.Get(fmt.Sprintf("%s/search?%s", server.URL, query))
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			return drainBody(t, resp)
+		}
+
+		cases := map[string]string{
+			"q=sushi":                "sushi",
+			"page=1&q=sushi":         "sushi",
+			"q=sushi&page=2":         "sushi page 2",
+			"lang=ja&page=2&q=sushi": "sushi page 2",
+			"page=2&q=sushi":         "exact",
+			"q=ramen":                "all",
+		}
+		for query, expected := range cases {
+			if body := get(query); body != expected {
+				t.Errorf("response body for %s should be %q: actual %s", query, expected, body)
+			}
+		}
+	})
+
+	t.Run("number of responses", func(t *testing.T) {
+		server := Launch(
+			Response{Method: "GET", Path: "/hello", Code: http.StatusOK},
+			Response{Method: "GET", Path: "/hello", Query: "dummy=1", Code: http.StatusOK},
+		).Add("POST", "/sushi", http.StatusCreated, "🍣")
+		server.Logger = t
+		defer server.Close()
+
+		if n := server.Len(); n != 3 {
+			t.Errorf("number of responses should be 3: actual %d", n)
+		}
+
+		server.Remove("GET", "/hello")
+		if n := server.Len(); n != 1 {
+			t.Errorf("number of responses should be 1: actual %d", n)
+		}
+	})
+
+	t.Run("with cookies", func(t *testing.T) {
+		server := Launch(
+			Response{
+				Method: "GET",
+				Path:   "/login",
+				Code:   http.StatusOK,
+				Cookies: []*http.Cookie{
+					&http.Cookie{Name: "session", Value: "abc"},
+					&http.Cookie{Name: "theme", Value: "dark", Path: "/"},
+				},
+			},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		resp, err := http.Get(fmt.Sprintf("%s/login", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		drainBody(t, resp)
+
+		if n := len(resp.Header["Set-Cookie"]); n != 2 {
+			t.Fatalf("Set-Cookie headers should be 2: actual %d", n)
+		}
+
+		cookies := resp.Cookies()
+		if cookies[0].Name != "session" || cookies[0].Value != "abc" {
+			t.Errorf("first cookie should be session=abc: actual %s", cookies[0])
+		}
+
+		if cookies[1].Name != "theme" || cookies[1].Value != "dark" {
+			t.Errorf("second cookie should be theme=dark: actual %s", cookies[1])
+		}
+	})
+
+	t.Run("multi-valued headers", func(t *testing.T) {
+		server := Launch(
+			Response{
+				Method:      "GET",
+				Path:        "/hello",
+				Code:        http.StatusOK,
+				ContentType: "text/plain",
+				Headers: map[string][]string{
+					"Vary":         []string{"Accept", "Accept-Encoding"},
+					"Content-Type": []string{"text/html"},
+				},
+			},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		resp, err := http.Get(fmt.Sprintf("%s/hello", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		drainBody(t, resp)
+
+		vary := resp.Header["Vary"]
+		if len(vary) != 2 || vary[0] != "Accept" || vary[1] != "Accept-Encoding" {
+			t.Errorf("Vary should be [Accept Accept-Encoding]: actual %v", vary)
+		}
+
+		// Headers should override ContentType
+		if ctype := resp.Header["Content-Type"]; len(ctype) != 1 || ctype[0] != "text/html" {
+			t.Errorf("Content-Type should be [text/html]: actual %v", ctype)
+		}
+	})
+
+	t.Run("default content type", func(t *testing.T) {
+		server := Launch().
+			Add("GET", "/html", http.StatusOK, "<html><body>hello, world</body></html>").
+			Add("GET", "/text", http.StatusOK, "hello, world")
+		server.Logger = t
+		defer server.Close()
+
+		cases := map[string]string{
+			"/html": "text/html; charset=utf-8",
+			"/text": "text/plain; charset=utf-8",
+		}
+		for path, expected := range cases {
+			resp, err := http.Get(server.URL + path)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			drainBody(t, resp)
+
+			if ctype := resp.Header.Get("Content-Type"); ctype != expected {
+				t.Errorf("Content-Type of %s should be sniffed as %s: actual %q", path, expected, ctype)
+			}
+		}
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		server := Launch().
+			AddRedirect("GET", "/old", "/new", http.StatusMovedPermanently).
+			Add("GET", "/new", http.StatusOK, "new page")
+		server.Logger = t
+		defer server.Close()
+
+		resp, err := http.Get(fmt.Sprintf("%s/old", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if body := drainBody(t, resp); body != "new page" {
+			t.Errorf("client should follow redirect: actual %s", body)
+		}
+
+		if n := server.CallCount("GET", "/old"); n != 1 {
+			t.Errorf("call count should be 1: actual %d", n)
+		}
+
+		defer func() {
+			if recover() == nil {
+				t.Errorf("AddRedirect should panic if code is not 3xx")
+			}
+		}()
+		server.AddRedirect("GET", "/invalid", "/new", http.StatusOK)
+	})
+
+	t.Run("with basic auth", func(t *testing.T) {
+		server := Launch(
+			Response{
+				Method: "GET",
+				Path:   "/secret",
+				Code:   http.StatusOK,
+				Body:   "secret",
+			}.RequireBasicAuth("user", "pass"),
+		)
+		server.Logger = t
+		defer server.Close()
+
+		get := func(user, pass string) *http.Response {
+			req, err := http.NewRequest("GET", fmt.Sprintf("%s/secret", server.URL), nil)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			if user != "" {
+				req.SetBasicAuth(user, pass)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			return resp
+		}
+
+		for _, creds := range [][]string{{"", ""}, {"user", "wrong"}} {
+			resp := get(creds[0], creds[1])
+			if resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("status code should be 401 Unauthorized: actual %d", resp.StatusCode)
+			}
+
+			if auth := resp.Header.Get("WWW-Authenticate"); auth == "" {
+				t.Errorf("WWW-Authenticate header should be set")
+			}
+
+			if body := drainBody(t, resp); body != "" {
+				t.Errorf("response body should be empty: actual %s", body)
+			}
+		}
+
+		resp := get("user", "pass")
+		if body := drainBody(t, resp); body != "secret" {
+			t.Errorf("response body should be \"secret\": actual %s", body)
+		}
+	})
+
+	t.Run("close with timeout", func(t *testing.T) {
+		server := Launch(
+			Response{
+				Method: "GET",
+				Path:   "/slow",
+				Code:   http.StatusOK,
+				Delay:  10 * time.Second,
+			},
+		)
+		server.Logger = t
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			resp, err := http.Get(fmt.Sprintf("%s/slow", server.URL))
+			if err == nil {
+				resp.Body.Close()
+			}
+		}()
+
+		// wait until the request arrives
+		for server.TotalRequests() == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		start := time.Now()
+		if err := server.CloseWithTimeout(100 * time.Millisecond); err == nil {
+			t.Errorf("CloseWithTimeout should return an error if requests are in flight")
+		}
+
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("CloseWithTimeout should return promptly: actual %s", elapsed)
+		}
+		<-done
+
+		idle := Launch()
+		idle.Logger = t
+		if err := idle.CloseWithTimeout(100 * time.Millisecond); err != nil {
+			t.Errorf("unexpected error : %+v", err)
+		}
+	})
+
+	t.Run("launch on fixed address", func(t *testing.T) {
+		// find a free port
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		addr := l.Addr().String()
+		l.Close()
+
+		server, err := LaunchOnAddr(addr, Response{Method: "GET", Path: "/hello", Code: http.StatusOK, Body: "hello, world"})
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		server.Logger = t
+		defer server.Close()
+
+		if server.URL != "http://"+addr {
+			t.Errorf("URL should be http://%s: actual %s", addr, server.URL)
+		}
+
+		resp, err := http.Get(fmt.Sprintf("http://%s/hello", addr))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if body := drainBody(t, resp); body != "hello, world" {
+			t.Errorf("response body should be \"hello, world\": actual %s", body)
+		}
+
+		if _, err := LaunchOnAddr(addr); err == nil {
+			t.Errorf("LaunchOnAddr should return an error if the address is already in use")
+		}
+	})
+
+	t.Run("with HTTP/2", func(t *testing.T) {
+		server := LaunchHTTP2().Add("GET", "/hello", http.StatusOK, "hello, world")
+		server.Logger = t
+		defer server.Close()
+
+		resp, err := server.Client().Get(fmt.Sprintf("%s/hello", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if resp.ProtoMajor != 2 {
+			t.Errorf("protocol should be HTTP/2: actual %s", resp.Proto)
+		}
+
+		if body := drainBody(t, resp); body != "hello, world" {
+			t.Errorf("response body should be \"hello, world\": actual %s", body)
+		}
+	})
+
+	t.Run("with inspect", func(t *testing.T) {
+		var inspected []string
+		inspect := func(r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			inspected = append(inspected, fmt.Sprintf("%s %s", r.URL.Path, body))
+		}
+		server := Launch(
+			Response{
+				Method:  "POST",
+				Path:    "/canned",
+				Code:    http.StatusCreated,
+				Body:    "canned",
+				Inspect: inspect,
+			},
+			Response{
+				Method:  "POST",
+				Path:    "/handler",
+				Inspect: inspect,
+				Handler: func(w http.ResponseWriter, r *http.Request) {
+					io.WriteString(w, "handler")
+				},
+			},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		for _, path := range []string{"/canned", "/handler"} {
+			resp, err := http.Post(server.URL+path, "text/plain", strings.NewReader("payload"))
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			if body := drainBody(t, resp); body != path[1:] {
+				t.Errorf("response body should be %q: actual %s", path[1:], body)
+			}
+		}
+
+		if len(inspected) != 2 || inspected[0] != "/canned payload" || inspected[1] != "/handler payload" {
+			t.Errorf("Inspect should be called for every request: actual %v", inspected)
+		}
+	})
+
+	t.Run("with middlewares", func(t *testing.T) {
+		var order []string
+		middleware := func(name string) func(http.Handler) http.Handler {
+			return func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					order = append(order, name)
+					w.Header().Add("X-Middleware", name)
+					next.ServeHTTP(w, r)
+				})
+			}
+		}
+		cors := func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+				if r.Method == "OPTIONS" {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				next.ServeHTTP(w, r)
+			})
+		}
+
+		server := Launch().Add("GET", "/hello", http.StatusOK, "hello, world")
+		server.Use(middleware("first")).Use(middleware("second")).Use(cors)
+		server.Logger = t
+		defer server.Close()
+
+		resp, err := http.Get(fmt.Sprintf("%s/hello", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		drainBody(t, resp)
+
+		if mw := resp.Header["X-Middleware"]; len(mw) != 2 || mw[0] != "first" || mw[1] != "second" {
+			t.Errorf("middlewares should run in order: actual %v", mw)
+		}
+
+		// middlewares should run for unmatched requests
+		req, err := http.NewRequest("OPTIONS", fmt.Sprintf("%s/unknown", server.URL), nil)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		resp, err = http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		drainBody(t, resp)
+
+		if resp.StatusCode != http.StatusNoContent {
+			t.Errorf("status code should be 204 No Content: actual %d", resp.StatusCode)
+		}
+
+		if origin := resp.Header.Get("Access-Control-Allow-Origin"); origin != "*" {
+			t.Errorf("Access-Control-Allow-Origin should be *: actual %s", origin)
+		}
+
+		if len(order) != 4 {
+			t.Errorf("middlewares should run for every request: actual %v", order)
+		}
+	})
+
+	t.Run("on match", func(t *testing.T) {
+		var matched []*Response
+		server := Launch(
+			Response{Method: "GET", Path: "/hello", Code: http.StatusOK, Body: "hello, world"},
+			Response{Method: "GET", Path: "/hello", Query: "dummy=1", Code: http.StatusOK, Body: "hello, query"},
+		)
+		server.OnMatch = func(r *http.Request, resp *Response) {
+			matched = append(matched, resp)
+		}
+		server.Logger = t
+		defer server.Close()
+
+		for _, path := range []string{"/hello?dummy=1", "/unknown"} {
+			resp, err := http.Get(server.URL + path)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			drainBody(t, resp)
+		}
+
+		if len(matched) != 1 {
+			t.Fatalf("OnMatch should be called only for matched requests: actual %d", len(matched))
+		}
+
+		if matched[0].Query != "dummy=1" {
+			t.Errorf("OnMatch should receive the selected response: actual %+v", matched[0])
+		}
+	})
+
+	t.Run("with level logger", func(t *testing.T) {
+		logger := levelLogger{}
+		server := Launch().Add("GET", "/hello", http.StatusOK, "hello, world")
+		server.Logger = &logger
+		defer server.Close()
+
+		for _, path := range []string{"/hello", "/unknown"} {
+			resp, err := http.Get(server.URL + path)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			drainBody(t, resp)
+		}
+
+		if len(logger.debug) != 1 || logger.debug[0] != "handler : %s %s (query: %q, matched query: %q) -> %+v" {
+			t.Errorf("matched requests should be logged as debug: actual %v", logger.debug)
+		}
+
+		if len(logger.warn) != 1 || logger.warn[0] != "unknown request: %s %s (query: %q)" {
+			t.Errorf("unknown requests should be logged as warning: actual %v", logger.warn)
+		}
+
+		if len(logger.logf) != 0 {
+			t.Errorf("Logf should not be used: actual %v", logger.logf)
+		}
+	})
+
+	t.Run("with default response", func(t *testing.T) {
+		server := Launch().Add("GET", "/hello", http.StatusOK, "hello, world")
+		server.SetDefaultResponse(Response{
+			Code:     http.StatusNotFound,
+			JSONBody: map[string]string{"error": "not found"},
+			Headers:  map[string][]string{"X-Default": []string{"true"}},
+		})
+		server.Logger = t
+		defer server.Close()
+
+		resp, err := http.Get(fmt.Sprintf("%s/unknown", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("status code should be 404 Not Found: actual %d", resp.StatusCode)
+		}
+
+		if ctype := resp.Header.Get("Content-Type"); ctype != "application/json" {
+			t.Errorf("ContentType should be application/json: actual %s", ctype)
+		}
+
+		if xh := resp.Header.Get("X-Default"); xh != "true" {
+			t.Errorf("X-Default should be true: actual %s", xh)
+		}
+
+		if body := drainBody(t, resp); body != `{"error":"not found"}` {
+			t.Errorf("response body should be the default response: actual %s", body)
+		}
+
+		// UnknownRequestHandler should take precedence
+		server.UnknownRequestHandler = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}
+
+		resp, err = http.Get(fmt.Sprintf("%s/unknown", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		drainBody(t, resp)
+
+		if resp.StatusCode != http.StatusTeapot {
+			t.Errorf("status code should be 418: actual %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("mirror HEAD", func(t *testing.T) {
+		server := Launch(
+			Response{
+				Method:  "GET",
+				Path:    "/hello",
+				Code:    http.StatusOK,
+				Body:    "hello, world",
+				Headers: map[string][]string{"X-Custom-Header": []string{"custom"}},
+			},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		head := func() *http.Response {
+			resp, err := http.Head(fmt.Sprintf("%s/hello", server.URL))
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			drainBody(t, resp)
+
+			return resp
+		}
+
+		if resp := head(); resp.Header.Get("X-Custom-Header") != "" {
+			t.Errorf("HEAD should not be mirrored by default")
+		}
+
+		server.MirrorHEAD(true)
+		resp := head()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("status code should be 200 OK: actual %d", resp.StatusCode)
+		}
+
+		if xh := resp.Header.Get("X-Custom-Header"); xh != "custom" {
+			t.Errorf("X-Custom-Header should be custom: actual %s", xh)
+		}
+
+		if resp.ContentLength != int64(len("hello, world")) {
+			t.Errorf("Content-Length should be %d: actual %d", len("hello, world"), resp.ContentLength)
+		}
+	})
+
+	t.Run("content length", func(t *testing.T) {
+		large := strings.Repeat("🍣", 10000)
+		server := Launch().
+			Add("GET", "/hello", http.StatusOK, "hello, world").
+			Add("GET", "/large", http.StatusOK, large)
+		server.Logger = t
+		defer server.Close()
+
+		for path, body := range map[string]string{"/hello": "hello, world", "/large": large} {
+			resp, err := http.Get(server.URL + path)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			drainBody(t, resp)
+
+			if cl := resp.Header.Get("Content-Length"); cl != strconv.Itoa(len(body)) {
+				t.Errorf("Content-Length of %s should be %d: actual %q", path, len(body), cl)
+			}
+
+			if len(resp.TransferEncoding) != 0 {
+				t.Errorf("response of %s should not be chunked: actual %v", path, resp.TransferEncoding)
+			}
+		}
+	})
+
+	t.Run("priority", func(t *testing.T) {
+		server := Launch(
+			Response{
+				Method:       "GET",
+				Path:         "/hello",
+				Code:         http.StatusOK,
+				Body:         "json",
+				MatchHeaders: map[string][]string{"Accept": []string{"application/json"}},
+			},
+			Response{
+				Method:       "GET",
+				Path:         "/hello",
+				Code:         http.StatusOK,
+				Body:         "authorized",
+				MatchHeaders: map[string][]string{"Authorization": []string{"Bearer token"}},
+				Priority:     1,
+			},
+			Response{
+				Method:       "GET",
+				Path:         "/hello",
+				Code:         http.StatusOK,
+				Body:         "second json",
+				MatchHeaders: map[string][]string{"Accept": []string{"application/json"}},
+			},
+			Response{
+				Method:    "GET",
+				Path:      "/.*",
+				PathRegex: true,
+				Code:      http.StatusServiceUnavailable,
+				Body:      "maintenance",
+				Priority:  2,
+				MatchQuery: url.Values{
+					"maintenance": []string{"1"},
+				},
+			},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		get := func(path string, headers map[string]string) string {
+			req, err := http.NewRequest("GET", server.URL+path, nil)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			for k, v := range headers {
+				req.Header.Set(k, v)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			return drainBody(t, resp)
+		}
+
+		// equal priorities fall back to registration order, and the one registered last wins
+		if body := get("/hello", map[string]string{"Accept": "application/json"}); body != "second json" {
+			t.Errorf("response body should be \"second json\": actual %s", body)
+		}
+
+		headers := map[string]string{"Accept": "application/json", "Authorization": "Bearer token"}
+		if body := get("/hello", headers); body != "authorized" {
+			t.Errorf("response body should be \"authorized\": actual %s", body)
+		}
+
+		// higher priority regex should win over exact path match
+		if body := get("/hello?maintenance=1", headers); body != "maintenance" {
+			t.Errorf("response body should be \"maintenance\": actual %s", body)
+		}
+	})
+
+	t.Run("response registered last wins", func(t *testing.T) {
+		server := Launch().
+			Add("GET", "/hello", http.StatusOK, "stale").
+			Add("GET", "/hello", http.StatusOK, "override")
+		server.Logger = t
+		defer server.Close()
+
+		resp, err := http.Get(fmt.Sprintf("%s/hello", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if body := drainBody(t, resp); body != "override" {
+			t.Errorf("response body should be \"override\": actual %s", body)
+		}
+	})
+
+	t.Run("most specific match wins regardless of registration order", func(t *testing.T) {
+		responses := []Response{
+			{Method: "GET", Path: "/hello", Code: http.StatusOK, Body: "no query"},
+			{Method: "GET", Path: "/hello", Code: http.StatusOK, Body: "match query", MatchQuery: url.Values{"a": []string{"1"}}},
+			{Method: "GET", Path: "/hello", Code: http.StatusOK, Body: "exact query", Query: "a=1&b=2"},
+			{
+				Method:       "GET",
+				Path:         "/hello",
+				Code:         http.StatusOK,
+				Body:         "match query with headers",
+				MatchQuery:   url.Values{"a": []string{"1"}},
+				MatchHeaders: map[string][]string{"Accept": []string{"text/plain"}},
+			},
+		}
+
+		cases := map[string]string{
+			"":        "no query",
+			"a=1":     "match query",
+			"a=1&b=2": "exact query",
+			"b=2&a=1": "match query",
+		}
+
+		// register responses in forward and reverse order
+		for _, reverse := range []bool{false, true} {
+			ordered := make([]Response, len(responses))
+			for i, resp := range responses {
+				if reverse {
+					i = len(responses) - 1 - i
+				}
+				ordered[i] = resp
+			}
+
+			server := Launch(ordered...)
+			server.Logger = t
+
+			for query, expected := range cases {
+				resp, err := http.Get(fmt.Sprintf("%s/hello?%s", server.URL, query))
+				if err != nil {
+					t.Fatalf("unexpected error : %+v", err)
+				}
+
+				if body := drainBody(t, resp); body != expected {
+					t.Errorf("response body for %q (reverse: %v) should be %q: actual %s", query, reverse, expected, body)
+				}
+			}
+
+			req, err := http.NewRequest("GET", fmt.Sprintf("%s/hello?a=1", server.URL), nil)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			req.Header.Set("Accept", "text/plain")
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			if body := drainBody(t, resp); body != "match query with headers" {
+				t.Errorf("response body (reverse: %v) should be \"match query with headers\": actual %s", reverse, body)
+			}
+
+			server.Close()
+		}
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		server := Launch().
+			Add("GET", "/hello", http.StatusOK, "hello, world").
+			AddEmptyResponse("DELETE", "/hello", http.StatusNoContent).
+			Add("GET", "/sushi", http.StatusOK, "🍣")
+		server.Logger = t
+		defer server.Close()
+
+		resp, err := http.Post(fmt.Sprintf("%s/hello", server.URL), "text/plain", nil)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		drainBody(t, resp)
+
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("status code should be 405 Method Not Allowed: actual %d", resp.StatusCode)
+		}
+
+		if allow := resp.Header.Get("Allow"); allow != "DELETE, GET" {
+			t.Errorf("Allow should be \"DELETE, GET\": actual %s", allow)
+		}
+
+		// unknown path should not be 405
+		resp, err = http.Post(fmt.Sprintf("%s/unknown", server.URL), "text/plain", nil)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		drainBody(t, resp)
+
+		if resp.StatusCode == http.StatusMethodNotAllowed {
+			t.Errorf("status code should not be 405 for unknown path")
+		}
+
+		// UnknownRequestHandler should take precedence
+		server.UnknownRequestHandler = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}
+
+		resp, err = http.Post(fmt.Sprintf("%s/hello", server.URL), "text/plain", nil)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		drainBody(t, resp)
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("status code should be 404 Not Found: actual %d", resp.StatusCode)
+		}
+
+		// default response should take precedence
+		server.UnknownRequestHandler = nil
+		server.SetDefaultResponse(Response{Code: http.StatusTeapot, Body: "default"})
+
+		resp, err = http.Post(fmt.Sprintf("%s/hello", server.URL), "text/plain", nil)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if body := drainBody(t, resp); resp.StatusCode != http.StatusTeapot || body != "default" {
+			t.Errorf("default response should be returned: actual %d %s", resp.StatusCode, body)
+		}
+	})
+
+	t.Run("with binary body", func(t *testing.T) {
+		png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\xff")
+		server := Launch(
+			Response{
+				Method:    "GET",
+				Path:      "/image.png",
+				Code:      http.StatusOK,
+				Body:      "ignored",
+				BodyBytes: png,
+			},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		resp, err := http.Get(fmt.Sprintf("%s/image.png", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if ctype := resp.Header.Get("Content-Type"); ctype != "image/png" {
+			t.Errorf("Content-Type should be image/png: actual %s", ctype)
+		}
+
+		if resp.ContentLength != int64(len(png)) {
+			t.Errorf("Content-Length should be %d: actual %d", len(png), resp.ContentLength)
+		}
+
+		if body := drainBody(t, resp); body != string(png) {
+			t.Errorf("response body should be the raw bytes: actual %q", body)
+		}
+	})
+
+	t.Run("with random delay", func(t *testing.T) {
+		resp := &Response{DelayMin: 10 * time.Millisecond, DelayMax: 50 * time.Millisecond, Delay: time.Hour}
+
+		delays := func(seed int64) []time.Duration {
+			server := Server{}
+			server.Seed(seed)
+
+			var delays []time.Duration
+			for i := 0; i < 10; i++ {
+				d := server.delay(resp)
+				if d < resp.DelayMin || d > resp.DelayMax {
+					t.Errorf("delay should be in [%s, %s]: actual %s", resp.DelayMin, resp.DelayMax, d)
+				}
+				delays = append(delays, d)
+			}
+
+			return delays
+		}
+
+		// delays should be reproducible with the same seed
+		if a, b := delays(42), delays(42); fmt.Sprint(a) != fmt.Sprint(b) {
+			t.Errorf("delays should be the same for the same seed: %v, %v", a, b)
+		}
+
+		server := Launch(
+			Response{Method: "GET", Path: "/slow", Code: http.StatusOK, DelayMin: 50 * time.Millisecond, DelayMax: 60 * time.Millisecond},
+		).Seed(1)
+		server.Logger = t
+		defer server.Close()
+
+		start := time.Now()
+		r, err := http.Get(fmt.Sprintf("%s/slow", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		drainBody(t, r)
+
+		if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+			t.Errorf("response should be delayed at least 50ms: actual %s", elapsed)
+		}
+	})
+
+	t.Run("rate limit", func(t *testing.T) {
+		server := Launch().
+			Add("GET", "/limited", http.StatusOK, "ok").
+			AddRateLimit("GET", "/limited", 2, time.Minute)
+		server.Logger = t
+		defer server.Close()
+
+		get := func() *http.Response {
+			resp, err := http.Get(fmt.Sprintf("%s/limited", server.URL))
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			drainBody(t, resp)
+
+			return resp
+		}
+
+		for i := 0; i < 2; i++ {
+			if resp := get(); resp.StatusCode != http.StatusOK {
+				t.Errorf("status code of request %d should be 200 OK: actual %d", i, resp.StatusCode)
+			}
+		}
+
+		resp := get()
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.Errorf("status code should be 429 Too Many Requests: actual %d", resp.StatusCode)
+		}
+
+		if retryAfter, _ := strconv.Atoi(resp.Header.Get("Retry-After")); retryAfter < 1 || retryAfter > 60 {
+			t.Errorf("Retry-After should be in [1, 60]: actual %s", resp.Header.Get("Retry-After"))
+		}
+
+		if count := server.CallCount("GET", "/limited"); count != 2 {
+			t.Errorf("rate limited request should not be counted: actual %d", count)
+		}
+
+		if total := server.TotalRequests(); total != 3 {
+			t.Errorf("total requests should be 3: actual %d", total)
+		}
+
+		server.Reset()
+		if resp := get(); resp.StatusCode != http.StatusOK {
+			t.Errorf("status code should be 200 OK after Reset: actual %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("with trickle", func(t *testing.T) {
+		server := Launch(
+			Response{
+				Method:  "GET",
+				Path:    "/trickle",
+				Code:    http.StatusOK,
+				Body:    "hello",
+				Trickle: 20 * time.Millisecond,
+			},
+			Response{
+				Method:  "GET",
+				Path:    "/endless",
+				Code:    http.StatusOK,
+				Body:    strings.Repeat("x", 1000),
+				Trickle: 10 * time.Second,
+			},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		start := time.Now()
+		resp, err := http.Get(fmt.Sprintf("%s/trickle", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if body := drainBody(t, resp); body != "hello" {
+			t.Errorf("response body should be \"hello\": actual %s", body)
+		}
+
+		if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+			t.Errorf("response body should be trickled for at least 80ms: actual %s", elapsed)
+		}
+
+		// handler should exit promptly when the client disconnects
+		client := &http.Client{Timeout: 100 * time.Millisecond}
+		resp, err = client.Get(fmt.Sprintf("%s/endless", server.URL))
+		if err == nil {
+			_, err = ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+
+		if err == nil {
+			t.Errorf("request should be timed out")
+		}
+	})
+
+	t.Run("with random codes", func(t *testing.T) {
+		codes := []int{http.StatusOK, http.StatusInternalServerError, http.StatusServiceUnavailable}
+		server := Launch(
+			Response{
+				Method:      "GET",
+				Path:        "/chaos",
+				Code:        http.StatusTeapot,
+				Body:        "chaos",
+				RandomCodes: codes,
+			},
+		).Seed(42)
+		server.Logger = t
+		defer server.Close()
+
+		get := func() []int {
+			var actual []int
+			for i := 0; i < 20; i++ {
+				resp, err := http.Get(fmt.Sprintf("%s/chaos", server.URL))
+				if err != nil {
+					t.Fatalf("unexpected error : %+v", err)
+				}
+
+				if body := drainBody(t, resp); body != "chaos" {
+					t.Errorf("response body should be \"chaos\": actual %s", body)
+				}
+
+				found := false
+				for _, code := range codes {
+					found = found || resp.StatusCode == code
+				}
+				if !found {
+					t.Errorf("status code should be one of %v: actual %d", codes, resp.StatusCode)
+				}
+				actual = append(actual, resp.StatusCode)
+			}
+
+			return actual
+		}
+
+		first := get()
+		server.Seed(42)
+		if second := get(); fmt.Sprint(first) != fmt.Sprint(second) {
+			t.Errorf("status codes should be the same for the same seed: %v, %v", first, second)
+		}
+	})
+
+	t.Run("echo", func(t *testing.T) {
+		server := Launch().AddEcho("POST", "/echo")
+		server.Logger = t
+		defer server.Close()
+
+		req, err := http.NewRequest("POST", fmt.Sprintf("%s/echo?q=1", server.URL), strings.NewReader("maguro"))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		req.Header.Set("X-Custom-Header", "custom")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if ctype := resp.Header.Get("Content-Type"); ctype != "application/json" {
+			t.Errorf("Content-Type should be application/json: actual %s", ctype)
+		}
+
+		var echo EchoResponse
+		if err := json.Unmarshal([]byte(drainBody(t, resp)), &echo); err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if echo.Method != "POST" || echo.Path != "/echo" || echo.Query != "q=1" || echo.Body != "maguro" {
+			t.Errorf("request should be echoed back: actual %+v", echo)
+		}
+
+		if xh := echo.Headers.Get("X-Custom-Header"); xh != "custom" {
+			t.Errorf("X-Custom-Header should be echoed back: actual %s", xh)
+		}
+	})
+
+	t.Run("with custom matcher", func(t *testing.T) {
+		server := Launch(
+			Response{Method: "GET", Path: "/hello", Code: http.StatusOK, Body: "hello, world"},
+			Response{
+				Method: "GET",
+				Path:   "/hello",
+				Code:   http.StatusOK,
+				Body:   "hello, mobile",
+				Match: func(r *http.Request) bool {
+					return strings.Contains(r.UserAgent(), "Mobile")
+				},
+			},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		get := func(userAgent string) string {
+			req, err := http.NewRequest("GET", fmt.Sprintf("%s/hello", server.URL), nil)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			req.Header.Set("User-Agent", userAgent)
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			return drainBody(t, resp)
+		}
+
+		if body := get("Mozilla/5.0 (iPhone) Mobile/15E148"); body != "hello, mobile" {
+			t.Errorf("response body should be \"hello, mobile\": actual %s", body)
+		}
+
+		if body := get("Mozilla/5.0 (Windows NT 10.0)"); body != "hello, world" {
+			t.Errorf("response body should be \"hello, world\": actual %s", body)
+		}
+	})
+
+	t.Run("with match host", func(t *testing.T) {
+		server := Launch(
+			Response{Method: "GET", Path: "/hello", Code: http.StatusOK, Body: "hello, anyone"},
+			Response{Method: "GET", Path: "/hello", MatchHost: "foo.example.com", Code: http.StatusOK, Body: "hello, foo"},
+			Response{Method: "GET", Path: "/hello", MatchHost: "bar.example.com", Code: http.StatusOK, Body: "hello, bar"},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		get := func(host string) string {
+			req, err := http.NewRequest("GET", fmt.Sprintf("%s/hello", server.URL), nil)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			if host != "" {
+				req.Host = host
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			return drainBody(t, resp)
+		}
+
+		for host, expected := range map[string]string{
+			"foo.example.com":      "hello, foo",
+			"BAR.example.com:8080": "hello, bar",
+			"baz.example.com":      "hello, anyone",
+			"":                     "hello, anyone",
+		} {
+			if body := get(host); body != expected {
+				t.Errorf("response body for host %q should be %q: actual %s", host, expected, body)
+			}
+		}
+	})
+
+	t.Run("with match query wildcards", func(t *testing.T) {
+		server := Launch(
+			Response{Method: "GET", Path: "/events", Code: http.StatusOK, Body: "all events"},
+			Response{Method: "GET", Path: "/events", MatchQuery: url.Values{"since": {"*"}}, Code: http.StatusOK, Body: "recent events"},
+			Response{Method: "GET", Path: "/events", MatchQuery: url.Values{"user": {"a*e"}, "since": {"*"}}, Code: http.StatusOK, Body: "recent events of user"},
+			Response{Method: "GET", Path: "/events", Query: "user=alice&since=1", Code: http.StatusOK, Body: "exact"},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		for query, expected := range map[string]string{
+			"":                            "all events",
+			"since=":                      "recent events",
+			"since=1500000000":            "recent events",
+			"since=1500000000&user=alice": "recent events of user",
+			"user=ae&since=1500000000":    "recent events of user",
+			"user=bob&since=1500000000":   "recent events",
+			"user=alice":                  "all events",
+			"user=alice&since=1":          "exact",
+		} {
+			resp, err := http.Get(fmt.Sprintf("%s/events?%s", server.URL, query))
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			if body := drainBody(t, resp); body != expected {
+				t.Errorf("response body for query %q should be %q: actual %s", query, expected, body)
+			}
+		}
+	})
+
+	t.Run("zero value server", func(t *testing.T) {
+		server := &Server{}
+		server.Start()
+		server.Logger = t
+		defer server.Close()
+
+		server.Add("GET", "/hello", http.StatusOK, "hello, world")
+
+		resp, err := http.Get(fmt.Sprintf("%s/hello", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("status code should be 200: actual %d", resp.StatusCode)
+		}
+
+		if body := drainBody(t, resp); body != "hello, world" {
+			t.Errorf("response body should be \"hello, world\": actual %s", body)
+		}
+
+		if count := server.CallCount("GET", "/hello"); count != 1 {
+			t.Errorf("call count should be 1: actual %d", count)
+		}
+	})
+
+	t.Run("snapshot and restore", func(t *testing.T) {
+		server := Launch(
+			Response{Method: "GET", Path: "/hello", Code: http.StatusOK, Body: "hello, world"},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		get := func(path string) *http.Response {
+			resp, err := http.Get(fmt.Sprintf("%s%s", server.URL, path))
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			return resp
+		}
+
+		drainBody(t, get("/hello"))
+		snapshot := server.Snapshot()
+
+		server.Remove("GET", "/hello")
+		server.Add("GET", "/hello", http.StatusOK, "temporary")
+		server.Add("GET", "/temporary", http.StatusOK, "temporary")
+
+		if body := drainBody(t, get("/hello")); body != "temporary" {
+			t.Errorf("response body should be \"temporary\": actual %s", body)
+		}
+		drainBody(t, get("/temporary"))
+		server.SetDefaultResponse(Response{Code: http.StatusTeapot, Body: "default"})
+		server.AddRateLimit("GET", "/hello", 0, time.Minute)
+
+		server.Restore(snapshot)
+
+		if _, ok := server.Durations()["GET /temporary"]; ok {
+			t.Errorf("durations should be restored")
+		}
+
+		if count := server.CallCount("GET", "/hello"); count != 1 {
+			t.Errorf("call count should be 1: actual %d", count)
+		}
+
+		if count := server.CallCount("GET", "/temporary"); count != 0 {
+			t.Errorf("call count should be 0: actual %d", count)
+		}
+
+		if body := drainBody(t, get("/hello")); body != "hello, world" {
+			t.Errorf("response body should be \"hello, world\": actual %s", body)
+		}
+
+		if body := drainBody(t, get("/temporary")); body != "" {
+			t.Errorf("response body should be empty: actual %s", body)
+		}
+
+		if total := server.TotalRequests(); total != 3 {
+			t.Errorf("total requests should be 3: actual %d", total)
+		}
+
+		// restoring a snapshot twice yields the same state
+		server.Restore(snapshot)
+		if server.Len() != 1 {
+			t.Errorf("number of responses should be 1: actual %d", server.Len())
+		}
+	})
+
+	t.Run("wait for request", func(t *testing.T) {
+		server := Launch(
+			Response{Method: "POST", Path: "/events", Code: http.StatusAccepted},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			resp, err := http.Post(fmt.Sprintf("%s/events", server.URL), "text/plain", strings.NewReader("event"))
+			if err == nil {
+				resp.Body.Close()
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		req, err := server.WaitForRequest(ctx, "POST", "/events")
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if string(req.Body) != "event" {
+			t.Errorf("request body should be \"event\": actual %s", req.Body)
+		}
+
+		ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		if _, err := server.WaitForRequest(ctx, "GET", "/events"); err == nil {
+			t.Errorf("WaitForRequest should fail when context is done")
+		}
+	})
+
+	t.Run("with max body size", func(t *testing.T) {
+		server := Launch(
+			Response{Method: "POST", Path: "/upload", Code: http.StatusCreated},
+		)
+		server.MaxBodySize = 8
+		server.Logger = t
+		defer server.Close()
+
+		url := fmt.Sprintf("%s/upload", server.URL)
+
+		resp, err := http.Post(url, "text/plain", strings.NewReader("12345678"))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		drainBody(t, resp)
+
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("status code should be 201: actual %d", resp.StatusCode)
+		}
+
+		// without Content-Length
+		body := struct{ io.Reader }{strings.NewReader("123456789")}
+		resp, err = http.Post(url, "text/plain", body)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		drainBody(t, resp)
+
+		if resp.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Errorf("status code should be 413: actual %d", resp.StatusCode)
+		}
+
+		if count := server.CallCount("POST", "/upload"); count != 1 {
+			t.Errorf("call count should be 1: actual %d", count)
+		}
+	})
+
+	t.Run("with trailers", func(t *testing.T) {
+		server := Launch(
+			Response{
+				Method:   "POST",
+				Path:     "/rpc",
+				Code:     http.StatusOK,
+				Body:     "payload",
+				Trailers: http.Header{"Grpc-Status": []string{"0"}, "Grpc-Message": []string{"OK"}},
+			},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		resp, err := http.Post(fmt.Sprintf("%s/rpc", server.URL), "application/grpc", nil)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if body := drainBody(t, resp); body != "payload" {
+			t.Errorf("response body should be \"payload\": actual %s", body)
+		}
+
+		if status := resp.Trailer.Get("Grpc-Status"); status != "0" {
+			t.Errorf("Grpc-Status trailer should be \"0\": actual %s", status)
+		}
+
+		if message := resp.Trailer.Get("Grpc-Message"); message != "OK" {
+			t.Errorf("Grpc-Message trailer should be \"OK\": actual %s", message)
+		}
+	})
+
+	t.Run("addr and port", func(t *testing.T) {
+		server := &Server{}
+		if server.Addr() != "" || server.Port() != 0 {
+			t.Errorf("addr and port should be empty before start: actual %q, %d", server.Addr(), server.Port())
+		}
+
+		for name, server := range map[string]*Server{
+			"http":  Launch(),
+			"https": LaunchTLS(),
+		} {
+			defer server.Close()
+
+			u, err := url.Parse(server.URL)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			if server.Addr() != u.Host {
+				t.Errorf("addr of %s server should be %s: actual %s", name, u.Host, server.Addr())
+			}
+
+			if strconv.Itoa(server.Port()) != u.Port() {
+				t.Errorf("port of %s server should be %s: actual %d", name, u.Port(), server.Port())
+			}
+		}
+	})
+
+	t.Run("new without start", func(t *testing.T) {
+		server := New(
+			Response{Method: "GET", Path: "/hello", Code: http.StatusOK, Body: "hello, world"},
+		)
+		if server.URL != "" {
+			t.Errorf("URL should be empty before start: actual %s", server.URL)
+		}
+
+		server.UnknownRequestHandler = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}
+		server.Logger = t
+		server.Start()
+		defer server.Close()
+
+		resp, err := http.Get(fmt.Sprintf("%s/hello", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if body := drainBody(t, resp); body != "hello, world" {
+			t.Errorf("response body should be \"hello, world\": actual %s", body)
+		}
+
+		resp, err = http.Get(fmt.Sprintf("%s/unknown", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		drainBody(t, resp)
+
+		if resp.StatusCode != http.StatusTeapot {
+			t.Errorf("status code should be 418: actual %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("with weight", func(t *testing.T) {
+		run := func(seed int64) string {
+			server := Launch(
+				Response{Method: "GET", Path: "/hello", Code: http.StatusOK, Body: "a", Weight: 80},
+				Response{Method: "GET", Path: "/hello", Code: http.StatusOK, Body: "b", Weight: 20},
+			).Seed(seed)
+			defer server.Close()
+
+			var bodies []string
+			for i := 0; i < 500; i++ {
+				resp, err := http.Get(fmt.Sprintf("%s/hello", server.URL))
+				if err != nil {
+					t.Fatalf("unexpected error : %+v", err)
+				}
+				bodies = append(bodies, drainBody(t, resp))
+			}
+
+			return strings.Join(bodies, "")
+		}
+
+		bodies := run(1)
+		if a := strings.Count(bodies, "a"); a < 350 || a > 450 {
+			t.Errorf("response a should be returned about 80%% of the time: actual %d of 500", a)
+		}
+
+		if run(1) != bodies {
+			t.Errorf("responses should be deterministic with the same seed")
+		}
+	})
+
+	t.Run("match without serving", func(t *testing.T) {
+		server := New(
+			Response{Method: "GET", Path: "/hello", Code: http.StatusOK, Body: "hello, world"},
+			Response{Method: "GET", Path: "/hello", Query: "dummy=1", Code: http.StatusOK, Body: "hello, world with query string"},
+		)
+
+		for target, expected := range map[string]string{
+			"/hello":         "hello, world",
+			"/hello?dummy=1": "hello, world with query string",
+		} {
+			resp := server.Match(httptest.NewRequest("GET", target, nil))
+			if resp == nil {
+				t.Fatalf("response for %s should be matched", target)
+			}
+			if resp.Body != expected {
+				t.Errorf("response body for %s should be %q: actual %s", target, expected, resp.Body)
+			}
+		}
+
+		if resp := server.Match(httptest.NewRequest("POST", "/hello", nil)); resp != nil {
+			t.Errorf("response for POST /hello should be nil: actual %+v", resp)
+		}
+
+		if count := server.CallCount("GET", "/hello"); count != 0 {
+			t.Errorf("call count should be 0: actual %d", count)
+		}
+	})
+
+	t.Run("durations", func(t *testing.T) {
+		server := Launch(
+			Response{Method: "GET", Path: "/slow", Code: http.StatusOK, Delay: 50 * time.Millisecond},
+			Response{Method: "GET", Path: "/fast", Code: http.StatusOK},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		if d := server.LastDuration(); d != 0 {
+			t.Errorf("last duration should be 0 before requests: actual %s", d)
+		}
+
+		for _, path := range []string{"/slow", "/slow", "/fast"} {
+			resp, err := http.Get(fmt.Sprintf("%s%s", server.URL, path))
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			drainBody(t, resp)
+		}
+
+		// durations are recorded after the response is written
+		durations := server.Durations()
+		for deadline := time.Now().Add(time.Second); durations["GET /fast"] == 0 && time.Now().Before(deadline); {
+			time.Sleep(time.Millisecond)
+			durations = server.Durations()
+		}
+
+		if d := durations["GET /slow"]; d < 50*time.Millisecond {
+			t.Errorf("average duration of GET /slow should be at least 50ms: actual %s", d)
+		}
+
+		if d := durations["GET /fast"]; d <= 0 || d >= 50*time.Millisecond {
+			t.Errorf("average duration of GET /fast should be less than 50ms: actual %s", d)
+		}
+
+		if d := server.LastDuration(); d != durations["GET /fast"] {
+			t.Errorf("last duration should be %s: actual %s", durations["GET /fast"], d)
+		}
+	})
+
+	t.Run("with auto options", func(t *testing.T) {
+		server := Launch().
+			Add("GET", "/hello", http.StatusOK, "hello, world").
+			Add("POST", "/hello", http.StatusCreated, "created").
+			Add("OPTIONS", "/explicit", http.StatusNoContent, "")
+		server.Logger = t
+		defer server.Close()
+
+		options := func(path string) *http.Response {
+			req, err := http.NewRequest("OPTIONS", fmt.Sprintf("%s%s", server.URL, path), nil)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			drainBody(t, resp)
+
+			return resp
+		}
+
+		if resp := options("/hello"); resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("status code should be 405 unless enabled: actual %d", resp.StatusCode)
+		}
+
+		server.AutoOptions(true)
+
+		resp := options("/hello")
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("status code should be 200: actual %d", resp.StatusCode)
+		}
+		if allow := resp.Header.Get("Allow"); allow != "GET, POST, OPTIONS" {
+			t.Errorf("Allow header should be \"GET, POST, OPTIONS\": actual %s", allow)
+		}
+
+		if resp := options("/explicit"); resp.StatusCode != http.StatusNoContent {
+			t.Errorf("status code should be 204: actual %d", resp.StatusCode)
+		}
+
+		if resp := options("/unknown"); resp.Header.Get("Allow") != "" {
+			t.Errorf("Allow header should be empty for unknown path: actual %s", resp.Header.Get("Allow"))
+		}
+	})
+
+	t.Run("zero code", func(t *testing.T) {
+		server := Launch(
+			Response{Method: "GET", Path: "/hello", Body: "hello, world"},
+		).AddEmptyResponse("GET", "/empty", 0)
+		server.Logger = t
+		defer server.Close()
+
+		for path, expected := range map[string]string{"/hello": "hello, world", "/empty": ""} {
+			resp, err := http.Get(fmt.Sprintf("%s%s", server.URL, path))
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			if body := drainBody(t, resp); body != expected {
+				t.Errorf("response body of %s should be %q: actual %s", path, expected, body)
+			}
+
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status code of %s should be 200: actual %d", path, resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("with variants", func(t *testing.T) {
+		server := Launch(
+			Response{
+				Method: "GET",
+				Path:   "/data",
+				Code:   http.StatusOK,
+				Body:   "not acceptable",
+				Variants: map[string]Response{
+					"application/json": {Body: `{"message": "hello"}`},
+					"application/xml":  {Body: "<message>hello</message>"},
+					"text/plain":       {Code: http.StatusAccepted, Body: "hello"},
+				},
+			},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		for accept, expected := range map[string]struct {
+			code        int
+			contentType string
+			body        string
+		}{
+			"application/json":                        {http.StatusOK, "application/json", `{"message": "hello"}`},
+			"application/xml, application/json;q=0.5": {http.StatusOK, "application/xml", "<message>hello</message>"},
+			"application/json;q=0.1, text/*":          {http.StatusAccepted, "text/plain", "hello"},
+			"image/png":                               {http.StatusOK, "text/plain; charset=utf-8", "not acceptable"},
+			"*/*":                                     {http.StatusOK, "application/json", `{"message": "hello"}`},
+			"*/*, text/plain":                         {http.StatusAccepted, "text/plain", "hello"},
+			"*/*, application/*;q=0.5, text/*":        {http.StatusAccepted, "text/plain", "hello"},
+		} {
+			req, err := http.NewRequest("GET", fmt.Sprintf("%s/data", server.URL), nil)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			req.Header.Set("Accept", accept)
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			if body := drainBody(t, resp); body != expected.body {
+				t.Errorf("response body for %q should be %q: actual %s", accept, expected.body, body)
+			}
+
+			if resp.StatusCode != expected.code {
+				t.Errorf("status code for %q should be %d: actual %d", accept, expected.code, resp.StatusCode)
+			}
+
+			if ctype := resp.Header.Get("Content-Type"); ctype != expected.contentType {
+				t.Errorf("Content-Type for %q should be %q: actual %s", accept, expected.contentType, ctype)
+			}
+
+			if vary := resp.Header.Get("Vary"); vary != "Accept" {
+				t.Errorf("Vary header should be \"Accept\": actual %s", vary)
+			}
+		}
+	})
+
+	t.Run("with variants keeping parent settings", func(t *testing.T) {
+		server := Launch(
+			Response{
+				Method:  "GET",
+				Path:    "/data",
+				Body:    "hello",
+				Gzip:    true,
+				Headers: http.Header{"X-Foo": {"foo"}},
+				Cookies: []*http.Cookie{{Name: "session", Value: "abc"}},
+				Variants: map[string]Response{
+					"application/json": {Body: `{"message": "hello"}`, Headers: http.Header{"X-Bar": {"bar"}}},
+				},
+			},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		req, err := http.NewRequest("GET", fmt.Sprintf("%s/data", server.URL), nil)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if body := drainBody(t, resp); body != `{"message": "hello"}` {
+			t.Errorf("response body should be the variant: actual %s", body)
+		}
+
+		if !resp.Uncompressed {
+			t.Errorf("response body should be compressed")
+		}
+
+		if foo, bar := resp.Header.Get("X-Foo"), resp.Header.Get("X-Bar"); foo != "foo" || bar != "bar" {
+			t.Errorf("headers of the response and the variant should be set: actual %q, %q", foo, bar)
+		}
+
+		if cookies := resp.Cookies(); len(cookies) != 1 || cookies[0].Value != "abc" {
+			t.Errorf("cookies of the response should be set: actual %+v", cookies)
+		}
+	})
+
+	t.Run("connection reuse after large request body", func(t *testing.T) {
+		server := Launch(
+			Response{Method: "POST", Path: "/upload", Code: http.StatusCreated},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		client := &http.Client{Transport: &http.Transport{}}
+		body := strings.Repeat("x", 1<<20)
+
+		var reused []bool
+		for i := 0; i < 2; i++ {
+			req, err := http.NewRequest("POST", fmt.Sprintf("%s/upload", server.URL), strings.NewReader(body))
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+				GotConn: func(info httptrace.GotConnInfo) {
+					reused = append(reused, info.Reused)
+				},
+			}))
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			drainBody(t, resp)
+		}
+
+		if len(reused) != 2 || !reused[1] {
+			t.Errorf("connection should be reused: actual %v", reused)
+		}
+	})
+
+	t.Run("add multi", func(t *testing.T) {
+		server := Launch().AddMulti([]string{"GET", "post"}, "/hello", Response{Code: http.StatusOK, Body: "hello, world"})
+		server.Logger = t
+		defer server.Close()
+
+		for _, method := range []string{"GET", "POST"} {
+			req, err := http.NewRequest(method, fmt.Sprintf("%s/hello", server.URL), nil)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			if body := drainBody(t, resp); body != "hello, world" {
+				t.Errorf("response body of %s should be \"hello, world\": actual %s", method, body)
+			}
+		}
+
+		if server.Len() != 2 {
+			t.Errorf("number of responses should be 2: actual %d", server.Len())
+		}
+	})
+
+	t.Run("no content", func(t *testing.T) {
+		server := Launch(
+			Response{Method: "DELETE", Path: "/hello", Code: http.StatusNoContent, ContentType: "application/json", Body: "ignored"},
+		).AddEmptyResponse("PUT", "/hello", http.StatusNoContent)
+		server.Logger = t
+		defer server.Close()
+
+		for _, method := range []string{"DELETE", "PUT"} {
+			req, err := http.NewRequest(method, fmt.Sprintf("%s/hello", server.URL), nil)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			if body := drainBody(t, resp); body != "" {
+				t.Errorf("response body of %s should be empty: actual %s", method, body)
+			}
+
+			if resp.StatusCode != http.StatusNoContent {
+				t.Errorf("status code of %s should be 204: actual %d", method, resp.StatusCode)
+			}
+
+			for _, key := range []string{"Content-Type", "Content-Length"} {
+				if _, ok := resp.Header[key]; ok {
+					t.Errorf("%s header of %s should be omitted: actual %s", key, method, resp.Header.Get(key))
+				}
+			}
+		}
+	})
+
+	t.Run("received bodies", func(t *testing.T) {
+		server := Launch(
+			Response{Method: "POST", Path: "/batch", Code: http.StatusAccepted},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		for _, path := range []string{"/batch", "/other", "/batch"} {
+			resp, err := http.Post(fmt.Sprintf("%s%s", server.URL, path), "text/plain", strings.NewReader("page"+path))
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			drainBody(t, resp)
+		}
+
+		bodies := server.ReceivedBodies("post", "/batch")
+		if len(bodies) != 2 || string(bodies[0]) != "page/batch" || string(bodies[1]) != "page/batch" {
+			t.Fatalf("received bodies should be two \"page/batch\": actual %q", bodies)
+		}
+
+		// returned bodies are copies
+		bodies[0][0] = 'x'
+		if body := server.ReceivedBodies("POST", "/batch")[0]; string(body) != "page/batch" {
+			t.Errorf("received body should not be modified: actual %s", body)
+		}
+
+		if bodies := server.ReceivedBodies("GET", "/batch"); len(bodies) != 0 {
+			t.Errorf("received bodies of GET /batch should be empty: actual %q", bodies)
+		}
+	})
+
+	t.Run("with match cookies", func(t *testing.T) {
+		server := Launch(
+			Response{Method: "GET", Path: "/me", Code: http.StatusUnauthorized, Body: "anonymous"},
+			Response{Method: "GET", Path: "/me", MatchCookies: map[string]string{"session": "alice"}, Code: http.StatusOK, Body: "alice"},
+			Response{Method: "GET", Path: "/me", MatchCookies: map[string]string{"session": "alice", "lang": "ja"}, Code: http.StatusOK, Body: "アリス"},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		for _, c := range []struct {
+			cookies  []*http.Cookie
+			expected string
+		}{
+			{nil, "anonymous"},
+			{[]*http.Cookie{{Name: "session", Value: "bob"}}, "anonymous"},
+			{[]*http.Cookie{{Name: "session", Value: "alice"}}, "alice"},
+			{[]*http.Cookie{{Name: "lang", Value: "ja"}, {Name: "session", Value: "alice"}}, "アリス"},
+		} {
+			req, err := http.NewRequest("GET", fmt.Sprintf("%s/me", server.URL), nil)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			for _, cookie := range c.cookies {
+				req.AddCookie(cookie)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			if body := drainBody(t, resp); body != c.expected {
+				t.Errorf("response body for cookies %v should be %q: actual %s", c.cookies, c.expected, body)
+			}
+		}
+	})
+
+	t.Run("mount handler", func(t *testing.T) {
+		server := New(
+			Response{Method: "GET", Path: "/api/hello", Code: http.StatusOK, Body: "hello, world"},
+		)
+		server.Logger = t
+
+		mux := http.NewServeMux()
+		mux.Handle("/api/", server.Handler())
+		mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "ok")
+		})
+
+		ts := httptest.NewServer(mux)
+		defer ts.Close()
+
+		for path, expected := range map[string]string{"/api/hello": "hello, world", "/health": "ok"} {
+			resp, err := http.Get(fmt.Sprintf("%s%s", ts.URL, path))
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			if body := drainBody(t, resp); body != expected {
+				t.Errorf("response body of %s should be %q: actual %s", path, expected, body)
+			}
+		}
+
+		if count := server.CallCount("GET", "/api/hello"); count != 1 {
+			t.Errorf("call count should be 1: actual %d", count)
+		}
+	})
+
+	t.Run("with context func", func(t *testing.T) {
+		type traceIDKey struct{}
+
+		server := Launch(
+			Response{
+				Method: "GET",
+				Path:   "/hello",
+				Handler: func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprintf(w, "trace %s", r.Context().Value(traceIDKey{}))
+				},
+			},
+		)
+		server.ContextFunc = func(ctx context.Context, r *http.Request) context.Context {
+			return context.WithValue(ctx, traceIDKey{}, r.Header.Get("X-Trace-Id"))
+		}
+		server.Logger = t
+		defer server.Close()
+
+		req, err := http.NewRequest("GET", fmt.Sprintf("%s/hello", server.URL), nil)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		req.Header.Set("X-Trace-Id", "abc123")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if body := drainBody(t, resp); body != "trace abc123" {
+			t.Errorf("response body should be \"trace abc123\": actual %s", body)
+		}
+	})
+
+	t.Run("with gzip body file", func(t *testing.T) {
+		server := Launch(
+			Response{Method: "GET", Path: "/hello", Code: http.StatusOK, ContentType: "application/json", BodyFile: "testdata/hello.json.gz"},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		compressed, err := ioutil.ReadFile("testdata/hello.json.gz")
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		plain, err := ioutil.ReadFile("testdata/hello.json")
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+		for acceptEncoding, expected := range map[string]struct {
+			encoding string
+			body     []byte
+		}{
+			"gzip": {"gzip", compressed},
+			"":     {"", plain},
+		} {
+			req, err := http.NewRequest("GET", fmt.Sprintf("%s/hello", server.URL), nil)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			if acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", acceptEncoding)
+			}
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			if body := drainBody(t, resp); body != string(expected.body) {
+				t.Errorf("response body for Accept-Encoding %q should be %q: actual %q", acceptEncoding, expected.body, body)
+			}
+
+			if encoding := resp.Header.Get("Content-Encoding"); encoding != expected.encoding {
+				t.Errorf("Content-Encoding for Accept-Encoding %q should be %q: actual %s", acceptEncoding, expected.encoding, encoding)
+			}
+
+			if ctype := resp.Header.Get("Content-Type"); ctype != "application/json" {
+				t.Errorf("Content-Type should be \"application/json\": actual %s", ctype)
+			}
+		}
+	})
+
+	t.Run("with expect 100-continue", func(t *testing.T) {
+		server := Launch(
+			Response{Method: "PUT", Path: "/upload", Code: http.StatusCreated},
+		)
+		server.MaxBodySize = 1024
+		server.Logger = t
+		defer server.Close()
+
+		client := &http.Client{Transport: &http.Transport{ExpectContinueTimeout: 5 * time.Second}}
+		upload := func(body string) (*http.Response, bool) {
+			req, err := http.NewRequest("PUT", fmt.Sprintf("%s/upload", server.URL), strings.NewReader(body))
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			req.Header.Set("Expect", "100-continue")
+
+			continued := false
+			req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+				Got100Continue: func() {
+					continued = true
+				},
+			}))
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			drainBody(t, resp)
+
+			return resp, continued
+		}
+
+		resp, continued := upload("payload")
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("status code should be 201: actual %d", resp.StatusCode)
+		}
+		if !continued {
+			t.Errorf("100 Continue should be sent")
+		}
+
+		if body := server.ReceivedBodies("PUT", "/upload"); len(body) != 1 || string(body[0]) != "payload" {
+			t.Errorf("received body should be \"payload\": actual %q", body)
+		}
+
+		resp, continued = upload(strings.Repeat("x", 2048))
+		if resp.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Errorf("status code should be 413: actual %d", resp.StatusCode)
+		}
+		if continued {
+			t.Errorf("100 Continue should not be sent for too large body")
+		}
+		if !resp.Close {
+			t.Errorf("connection should be closed since the body is left unread")
+		}
+	})
+
+	t.Run("set replaces responses", func(t *testing.T) {
+		server := Launch().
+			Add("GET", "/hello", http.StatusOK, "hello, world").
+			AddResponses(Response{Method: "GET", Path: "/hello", Query: "dummy=1", Code: http.StatusOK, Body: "with query"}).
+			Add("GET", "/hello", http.StatusOK, "duplicated")
+		server.Logger = t
+		defer server.Close()
+
+		server.Set("get", "/hello", Response{Code: http.StatusOK, Body: "replaced"})
+		server.Set("GET", "/new", Response{Code: http.StatusCreated, Body: "new"})
+
+		if server.Len() != 3 {
+			t.Errorf("number of responses should be 3: actual %d", server.Len())
+		}
+
+		for path, expected := range map[string]string{
+			"/hello":         "replaced",
+			"/hello?dummy=1": "with query",
+			"/new":           "new",
+		} {
+			resp, err := http.Get(fmt.Sprintf("%s%s", server.URL, path))
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			if body := drainBody(t, resp); body != expected {
+				t.Errorf("response body of %s should be %q: actual %s", path, expected, body)
+			}
+		}
+
+		// state of the replaced response should be dropped
+		server.AddSequence("GET", "/job", Response{Body: "pending"}, Response{Body: "done"})
+		resp, err := http.Get(fmt.Sprintf("%s/job", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		drainBody(t, resp)
+
+		server.Set("GET", "/job", Response{Body: "replaced"})
+		if n := len(server.sequenceIndexes); n != 0 {
+			t.Errorf("sequence position of the replaced response should be dropped: actual %d", n)
+		}
+	})
+
+	t.Run("with JSON unknown handler", func(t *testing.T) {
+		server := Launch()
+		server.UnknownRequestHandler = server.JSONUnknownHandler()
+		server.Logger = t
+		defer server.Close()
+
+		resp, err := http.Post(fmt.Sprintf("%s/x?a=1", server.URL), "text/plain", nil)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if body := drainBody(t, resp); body != `{"error":"no mock for POST /x"}` {
+			t.Errorf("response body should be JSON error: actual %s", body)
+		}
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("status code should be 404: actual %d", resp.StatusCode)
+		}
+
+		if ctype := resp.Header.Get("Content-Type"); ctype != "application/json" {
+			t.Errorf("Content-Type should be \"application/json\": actual %s", ctype)
+		}
+	})
+
+	t.Run("with default content type", func(t *testing.T) {
+		server := Launch(
+			Response{
+				Method: "GET",
+				Path:   "/handler",
+				Handler: func(w http.ResponseWriter, r *http.Request) {
+					io.WriteString(w, "<html></html>")
+				},
+			},
+			Response{
+				Method: "GET",
+				Path:   "/override",
+				Handler: func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "text/csv")
+					io.WriteString(w, "a,b")
+				},
+			},
+			Response{Method: "GET", Path: "/body", Code: http.StatusOK, Body: "<html></html>"},
+			Response{Method: "GET", Path: "/explicit", Code: http.StatusOK, ContentType: "text/html", Body: "<html></html>"},
+		)
+		server.DefaultContentType = "application/json"
+		server.Logger = t
+		defer server.Close()
+
+		for path, expected := range map[string]string{
+			"/handler":  "application/json",
+			"/override": "text/csv",
+			"/body":     "application/json",
+			"/explicit": "text/html",
+		} {
+			resp, err := http.Get(fmt.Sprintf("%s%s", server.URL, path))
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			drainBody(t, resp)
+
+			if ctype := resp.Header.Get("Content-Type"); ctype != expected {
+				t.Errorf("Content-Type of %s should be %q: actual %s", path, expected, ctype)
+			}
+		}
+	})
+
+	t.Run("disable keep-alives", func(t *testing.T) {
+		server := Launch().Add("GET", "/hello", http.StatusOK, "hello, world").DisableKeepAlives()
+		server.Logger = t
+		defer server.Close()
+
+		client := &http.Client{Transport: &http.Transport{}}
+
+		var reused []bool
+		for i := 0; i < 3; i++ {
+			req, err := http.NewRequest("GET", fmt.Sprintf("%s/hello", server.URL), nil)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+				GotConn: func(info httptrace.GotConnInfo) {
+					reused = append(reused, info.Reused)
+				},
+			}))
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+			drainBody(t, resp)
+
+			if !resp.Close {
+				t.Errorf("response should have Connection: close")
+			}
+		}
+
+		for i, r := range reused {
+			if r {
+				t.Errorf("connection of request #%d should not be reused", i)
+			}
+		}
+	})
+
+	t.Run("with match content type", func(t *testing.T) {
+		server := Launch(
+			Response{Method: "POST", Path: "/upload", Code: http.StatusUnsupportedMediaType},
+			Response{Method: "POST", Path: "/upload", MatchContentType: "application/json", Code: http.StatusOK, Body: "json"},
+			Response{Method: "POST", Path: "/upload", MatchContentType: "multipart/*", Code: http.StatusOK, Body: "multipart"},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		for contentType, expected := range map[string]string{
+			"application/json":                      "json",
+			"Application/JSON; charset=utf-8":       "json",
+			"multipart/form-data; boundary=xxxxxxx": "multipart",
+			"text/plain":                            "",
+		} {
+			resp, err := http.Post(fmt.Sprintf("%s/upload", server.URL), contentType, strings.NewReader("body"))
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			if body := drainBody(t, resp); body != expected {
+				t.Errorf("response body for %q should be %q: actual %s", contentType, expected, body)
+			}
+		}
+	})
+
+	t.Run("method helpers", func(t *testing.T) {
+		server := Launch().
+			Get("/items", http.StatusOK, "get").
+			Post("/items", http.StatusCreated, "post").
+			Put("/items", http.StatusOK, "put").
+			Patch("/items", http.StatusOK, "patch").
+			Delete("/items", http.StatusAccepted, "delete")
+		server.Logger = t
+		defer server.Close()
+
+		for method, expected := range map[string]struct {
+			code int
+			body string
+		}{
+			"GET":    {http.StatusOK, "get"},
+			"POST":   {http.StatusCreated, "post"},
+			"PUT":    {http.StatusOK, "put"},
+			"PATCH":  {http.StatusOK, "patch"},
+			"DELETE": {http.StatusAccepted, "delete"},
+		} {
+			req, err := http.NewRequest(method, fmt.Sprintf("%s/items", server.URL), nil)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			if body := drainBody(t, resp); body != expected.body {
+				t.Errorf("response body of %s should be %q: actual %s", method, expected.body, body)
+			}
+
+			if resp.StatusCode != expected.code {
+				t.Errorf("status code of %s should be %d: actual %d", method, expected.code, resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("add error", func(t *testing.T) {
+		server := Launch().AddError("GET", "/fail", http.StatusServiceUnavailable, "service unavailable")
+		server.Logger = t
+		defer server.Close()
+
+		resp, err := http.Get(fmt.Sprintf("%s/fail", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if body := drainBody(t, resp); body != "service unavailable" {
+			t.Errorf("response body should be \"service unavailable\": actual %s", body)
+		}
+
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("status code should be 503: actual %d", resp.StatusCode)
+		}
+
+		if ctype := resp.Header.Get("Content-Type"); ctype != "text/plain" {
+			t.Errorf("Content-Type should be \"text/plain\": actual %s", ctype)
+		}
+	})
+
+	t.Run("with before hook", func(t *testing.T) {
+		server := Launch(
+			Response{Method: "GET", Path: "/now", Code: http.StatusOK, Body: "now", Headers: http.Header{"X-Original": []string{"1"}}},
+		)
+		n := 0
+		server.Before = func(r *http.Request, resp *Response) {
+			n++
+			resp.Body = fmt.Sprintf("%s %d", resp.Body, n)
+			resp.Headers.Set("X-Original", "modified")
+		}
+		server.Logger = t
+		defer server.Close()
+
+		for i := 1; i <= 2; i++ {
+			resp, err := http.Get(fmt.Sprintf("%s/now", server.URL))
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			if body, expected := drainBody(t, resp), fmt.Sprintf("now %d", i); body != expected {
+				t.Errorf("response body should be %q: actual %s", expected, body)
+			}
+
+			if h := resp.Header.Get("X-Original"); h != "modified" {
+				t.Errorf("X-Original header should be \"modified\": actual %s", h)
+			}
+		}
+
+		stored := server.Responses["GET"]["/now"][0]
+		if stored.Body != "now" || stored.Headers.Get("X-Original") != "1" {
+			t.Errorf("stored response should not be modified: actual %+v", stored)
+		}
+	})
+
+	t.Run("with before hook changing templated body", func(t *testing.T) {
+		server := Launch(
+			Response{Method: "GET", Path: "/greet", BodyTemplate: "hello {{.Method}}"},
+			Response{Method: "GET", Path: "/plain", BodyTemplate: "hello {{.Method}}"},
+		)
+		server.Before = func(r *http.Request, resp *Response) {
+			if r.URL.Path == "/greet" {
+				resp.BodyTemplate = "bye {{.Path}}"
+				return
+			}
+			resp.BodyTemplate = ""
+			resp.Body = "plain"
+		}
+		server.Logger = t
+		defer server.Close()
+
+		for path, expected := range map[string]string{"/greet": "bye /greet", "/plain": "plain"} {
+			resp, err := http.Get(fmt.Sprintf("%s%s", server.URL, path))
+			if err != nil {
+				t.Fatalf("unexpected error : %+v", err)
+			}
+
+			if body := drainBody(t, resp); body != expected {
+				t.Errorf("response body of %s should be %q: actual %s", path, expected, body)
+			}
+		}
+	})
+
+	t.Run("with reflect headers", func(t *testing.T) {
+		server := Launch(
+			Response{Method: "GET", Path: "/hello", Code: http.StatusOK, Body: "hello, world", ReflectHeaders: []string{"x-request-id", "X-Trace-Id"}},
+		)
+		server.Logger = t
+		defer server.Close()
+
+		req, err := http.NewRequest("GET", fmt.Sprintf("%s/hello", server.URL), nil)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		req.Header.Set("X-Request-Id", "req-1")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		drainBody(t, resp)
+
+		if id := resp.Header.Get("X-Request-Id"); id != "req-1" {
+			t.Errorf("X-Request-Id header should be \"req-1\": actual %s", id)
+		}
+
+		if _, ok := resp.Header["X-Trace-Id"]; ok {
+			t.Errorf("X-Trace-Id header should be omitted: actual %q", resp.Header.Get("X-Trace-Id"))
+		}
+	})
+}
+
+// chanLogger : Logger which sends messages to the channel
+type chanLogger chan string
+
+func (l chanLogger) Logf(format string, args ...interface{}) {
+	l <- fmt.Sprintf(format, args...)
+}
+
+type customLogger struct {
+	msg  string
+	args []interface{}
+}
+
+func (l *customLogger) Logf(msg string, args ...interface{}) {
+	l.msg = msg
+	l.args = args
+}
+
+type levelLogger struct {
+	mu                      sync.Mutex
+	logf, debug, info, warn []string
+}
+
+func (l *levelLogger) Logf(msg string, args ...interface{}) { l.append(&l.logf, msg) }
+
+func (l *levelLogger) Debugf(msg string, args ...interface{}) { l.append(&l.debug, msg) }
+
+func (l *levelLogger) Infof(msg string, args ...interface{}) { l.append(&l.info, msg) }
+
+func (l *levelLogger) Warnf(msg string, args ...interface{}) { l.append(&l.warn, msg) }
+
+func (l *levelLogger) append(msgs *[]string, msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	*msgs = append(*msgs, msg)
 }
@@ -0,0 +1,24 @@
+package httpmocker
+
+// consume : marks given response with Once as served. It returns false if it has already been served.
+func (server *Server) consume(resp *Response) bool {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if server.consumed[resp] {
+		return false
+	}
+
+	if server.consumed == nil {
+		server.consumed = map[*Response]bool{}
+	}
+	server.consumed[resp] = true
+	return true
+}
+
+func (server *Server) isConsumed(resp *Response) bool {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	return server.consumed[resp]
+}
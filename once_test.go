@@ -0,0 +1,81 @@
+package httpmocker
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestOnce(t *testing.T) {
+	server := Launch(
+		Response{Method: "GET", Path: "/token", Code: http.StatusOK, Body: "fallback"},
+		Response{Method: "GET", Path: "/token", Code: http.StatusOK, Body: "first", Once: true, Priority: 1},
+		Response{Method: "GET", Path: "/nonce", Code: http.StatusOK, Body: "nonce", Once: true},
+	)
+	server.Logger = t
+	defer server.Close()
+
+	get := func(path string) string {
+		resp, err := http.Get(fmt.Sprintf("%s%s", server.URL, path))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		return drainBody(t, resp)
+	}
+
+	for i, expected := range []string{"first", "fallback", "fallback"} {
+		if body := get("/token"); body != expected {
+			t.Errorf("response body of request #%d should be %q: actual %s", i, expected, body)
+		}
+	}
+
+	if body := get("/nonce"); body != "nonce" {
+		t.Errorf("response body should be \"nonce\": actual %s", body)
+	}
+	if body := get("/nonce"); body != "" {
+		t.Errorf("response body should be empty after consumed: actual %s", body)
+	}
+
+	server.Reset()
+
+	if body := get("/token"); body != "first" {
+		t.Errorf("response body should be \"first\" after reset: actual %s", body)
+	}
+}
+
+func TestOnceConcurrentRequests(t *testing.T) {
+	server := Launch(
+		Response{Method: "GET", Path: "/token", Code: http.StatusOK, Body: "secret"}.RequireBasicAuth("user", "pass"),
+		Response{Method: "GET", Path: "/token", Code: http.StatusOK, Body: "first", Once: true, Priority: 1},
+	)
+	server.Logger = t
+	defer server.Close()
+
+	var mu sync.Mutex
+	codes := map[int]int{}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			resp, err := http.Get(fmt.Sprintf("%s/token", server.URL))
+			if err != nil {
+				t.Errorf("unexpected error : %+v", err)
+				return
+			}
+			drainBody(t, resp)
+
+			mu.Lock()
+			codes[resp.StatusCode]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	// requests losing the race should be checked against the fallback response
+	if codes[http.StatusOK] != 1 || codes[http.StatusUnauthorized] != 9 {
+		t.Errorf("only one request should be served without credentials: actual %v", codes)
+	}
+}
@@ -0,0 +1,307 @@
+package httpmocker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+type openAPIDocument struct {
+	Paths      map[string]openAPIPathItem `yaml:"paths"`
+	Components struct {
+		Schemas map[string]*openAPISchema `yaml:"schemas"`
+	} `yaml:"components"`
+}
+
+type openAPIPathItem struct {
+	Get     *openAPIOperation `yaml:"get"`
+	Put     *openAPIOperation `yaml:"put"`
+	Post    *openAPIOperation `yaml:"post"`
+	Delete  *openAPIOperation `yaml:"delete"`
+	Options *openAPIOperation `yaml:"options"`
+	Head    *openAPIOperation `yaml:"head"`
+	Patch   *openAPIOperation `yaml:"patch"`
+	Trace   *openAPIOperation `yaml:"trace"`
+}
+
+func (item openAPIPathItem) operations() map[string]*openAPIOperation {
+	return map[string]*openAPIOperation{
+		"GET":     item.Get,
+		"PUT":     item.Put,
+		"POST":    item.Post,
+		"DELETE":  item.Delete,
+		"OPTIONS": item.Options,
+		"HEAD":    item.Head,
+		"PATCH":   item.Patch,
+		"TRACE":   item.Trace,
+	}
+}
+
+type openAPIOperation struct {
+	// MapSlice keeps the order in which responses are defined
+	Responses yaml.MapSlice `yaml:"responses"`
+}
+
+type openAPIResponse struct {
+	Content map[string]openAPIMediaType `yaml:"content"`
+}
+
+type openAPIMediaType struct {
+	Schema   *openAPISchema `yaml:"schema"`
+	Example  interface{}    `yaml:"example"`
+	Examples map[string]struct {
+		Value interface{} `yaml:"value"`
+	} `yaml:"examples"`
+}
+
+type openAPISchema struct {
+	Ref        string                    `yaml:"$ref"`
+	Type       string                    `yaml:"type"`
+	Properties map[string]*openAPISchema `yaml:"properties"`
+	Items      *openAPISchema            `yaml:"items"`
+	Example    interface{}               `yaml:"example"`
+	Enum       []interface{}             `yaml:"enum"`
+}
+
+var openAPIPathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// openAPIPath : converts OpenAPI path template to a regex path
+func openAPIPath(path string) (string, bool) {
+	if !openAPIPathParam.MatchString(path) {
+		return path, false
+	}
+
+	var pattern string
+	last := 0
+	for _, loc := range openAPIPathParam.FindAllStringSubmatchIndex(path, -1) {
+		pattern += regexp.QuoteMeta(path[last:loc[0]])
+		if name := path[loc[2]:loc[3]]; pathPlaceholder.MatchString("{" + name + "}") {
+			pattern += "{" + name + "}"
+		} else {
+			pattern += "[^/]+"
+		}
+		last = loc[1]
+	}
+	pattern += regexp.QuoteMeta(path[last:])
+
+	return pattern, true
+}
+
+func (doc *openAPIDocument) responses() ([]Response, error) {
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var responses []Response
+	for _, path := range paths {
+		pattern, regex := openAPIPath(path)
+		operations := doc.Paths[path].operations()
+
+		methods := make([]string, 0, len(operations))
+		for method, op := range operations {
+			if op != nil {
+				methods = append(methods, method)
+			}
+		}
+		sort.Strings(methods)
+
+		for _, method := range methods {
+			op := operations[method]
+
+			resp, err := doc.response(op)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s : %v", method, path, err)
+			}
+
+			resp.Method = method
+			resp.Path = pattern
+			resp.PathRegex = regex
+			responses = append(responses, resp)
+		}
+	}
+
+	return responses, nil
+}
+
+// response : builds mock response from the first response defined in given operation
+func (doc *openAPIDocument) response(op *openAPIOperation) (Response, error) {
+	if len(op.Responses) == 0 {
+		return Response{}, fmt.Errorf("no responses are defined")
+	}
+
+	item := op.Responses[0]
+	code, err := strconv.Atoi(fmt.Sprint(item.Key))
+	if err != nil {
+		// "default" or ranges like "2XX"
+		code = http.StatusOK
+	}
+
+	// decode the response definition into typed struct
+	data, err := yaml.Marshal(item.Value)
+	if err != nil {
+		return Response{}, err
+	}
+	var def openAPIResponse
+	if err := yaml.Unmarshal(data, &def); err != nil {
+		return Response{}, err
+	}
+
+	resp := Response{Code: code}
+	if len(def.Content) == 0 {
+		return resp, nil
+	}
+
+	contentType := "application/json"
+	if _, ok := def.Content[contentType]; !ok {
+		types := make([]string, 0, len(def.Content))
+		for t := range def.Content {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		contentType = types[0]
+	}
+	resp.ContentType = contentType
+
+	example := doc.example(def.Content[contentType])
+	if example == nil {
+		return resp, nil
+	}
+
+	if s, ok := example.(string); ok && !strings.Contains(contentType, "json") {
+		resp.Body = s
+		return resp, nil
+	}
+
+	body, err := json.Marshal(example)
+	if err != nil {
+		return Response{}, err
+	}
+	resp.Body = string(body)
+
+	return resp, nil
+}
+
+// example : returns example of given media type, or generates it from the schema
+func (doc *openAPIDocument) example(media openAPIMediaType) interface{} {
+	if media.Example != nil {
+		return normalizeYAML(media.Example)
+	}
+
+	names := make([]string, 0, len(media.Examples))
+	for name := range media.Examples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if v := media.Examples[name].Value; v != nil {
+			return normalizeYAML(v)
+		}
+	}
+
+	if media.Schema == nil {
+		return nil
+	}
+
+	return doc.generate(media.Schema, 0)
+}
+
+// generate : generates example value from given schema
+func (doc *openAPIDocument) generate(schema *openAPISchema, depth int) interface{} {
+	// stop at recursive schemas
+	if schema == nil || depth > 8 {
+		return nil
+	}
+
+	if schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		return doc.generate(doc.Components.Schemas[name], depth+1)
+	}
+
+	if schema.Example != nil {
+		return normalizeYAML(schema.Example)
+	}
+
+	if len(schema.Enum) > 0 {
+		return normalizeYAML(schema.Enum[0])
+	}
+
+	switch schema.Type {
+	case "string":
+		return "string"
+	case "integer", "number":
+		return 0
+	case "boolean":
+		return false
+	case "array":
+		if item := doc.generate(schema.Items, depth+1); item != nil {
+			return []interface{}{item}
+		}
+		return []interface{}{}
+	default:
+		obj := map[string]interface{}{}
+		for name, prop := range schema.Properties {
+			obj[name] = doc.generate(prop, depth+1)
+		}
+		return obj
+	}
+}
+
+// normalizeYAML : converts maps decoded by yaml into values which can be marshaled as JSON
+func normalizeYAML(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[fmt.Sprint(k)] = normalizeYAML(val)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, val := range v {
+			list[i] = normalizeYAML(val)
+		}
+		return list
+	default:
+		return v
+	}
+}
+
+// loadOpenAPI : reads mock responses from given OpenAPI 3 document in YAML or JSON
+func loadOpenAPI(path string) ([]Response, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc openAPIDocument
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("httpmocker: failed to parse %s : %v", path, err)
+	}
+
+	responses, err := doc.responses()
+	if err != nil {
+		return nil, fmt.Errorf("httpmocker: failed to build mocks from %s : %v", path, err)
+	}
+
+	return responses, nil
+}
+
+// LaunchFromOpenAPI : launch mock server with one mock response per operation defined in given OpenAPI 3 document.
+// Each mock returns the first response status with its example, or a body generated from the schema.
+func LaunchFromOpenAPI(path string) (*Server, error) {
+	responses, err := loadOpenAPI(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Launch(responses...), nil
+}
@@ -0,0 +1,74 @@
+package httpmocker
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestLaunchFromOpenAPI(t *testing.T) {
+	server, err := LaunchFromOpenAPI("testdata/petstore.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error : %+v", err)
+	}
+	server.Logger = t
+	defer server.Close()
+
+	do := func(method, path string) (*http.Response, string) {
+		req, err := http.NewRequest(method, server.URL+path, nil)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		return resp, string(body)
+	}
+
+	resp, body := do("GET", "/pets")
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status code should be 200 OK : actual %d", resp.StatusCode)
+	}
+
+	if ctype := resp.Header.Get("Content-Type"); ctype != "application/json" {
+		t.Errorf("ContentType should be application/json: actual %s", ctype)
+	}
+
+	if body != `[{"id":1,"name":"tama"}]` {
+		t.Errorf("response body should be the example: actual %s", body)
+	}
+
+	// operations without examples should return an empty body with the declared status code
+	resp, body = do("POST", "/pets")
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("status code should be 201 Created : actual %d", resp.StatusCode)
+	}
+
+	if body != "" {
+		t.Errorf("response body should be empty: actual %s", body)
+	}
+
+	// path templates should be matched, and the body should be generated from the schema
+	resp, body = do("GET", "/pets/123")
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status code should be 200 OK : actual %d", resp.StatusCode)
+	}
+
+	if body != `{"id":1,"name":"string","tags":["string"]}` {
+		t.Errorf("response body should be generated from the schema: actual %s", body)
+	}
+
+	_, body = do("GET", "/health")
+	if body != "ok" {
+		t.Errorf("response body should be \"ok\": actual %s", body)
+	}
+}
@@ -0,0 +1,102 @@
+package httpmocker
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+)
+
+// Option : configures mock server launched by LaunchWithOptions
+type Option func(*options)
+
+type options struct {
+	responses             []Response
+	tls                   bool
+	http2                 bool
+	addr                  string
+	logger                Logger
+	unknownRequestHandler http.HandlerFunc
+}
+
+// WithResponses : registers given mock responses
+func WithResponses(responses ...Response) Option {
+	return func(o *options) {
+		o.responses = append(o.responses, responses...)
+	}
+}
+
+// WithTLS : serves over HTTPS. Use Client to make requests without TLS verification errors.
+func WithTLS() Option {
+	return func(o *options) {
+		o.tls = true
+	}
+}
+
+// WithHTTP2 : serves over HTTPS with HTTP/2 enabled
+func WithHTTP2() Option {
+	return func(o *options) {
+		o.tls = true
+		o.http2 = true
+	}
+}
+
+// WithAddr : listens on given address such as "127.0.0.1:8080" instead of a random port
+func WithAddr(addr string) Option {
+	return func(o *options) {
+		o.addr = addr
+	}
+}
+
+// WithLogger : sets Logger
+func WithLogger(logger Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithUnknownRequestHandler : sets UnknownRequestHandler
+func WithUnknownRequestHandler(handler http.HandlerFunc) Option {
+	return func(o *options) {
+		o.unknownRequestHandler = handler
+	}
+}
+
+// LaunchWithOptions : launch mock server configured by given options.
+// It returns an error if the address given by WithAddr is already in use.
+func LaunchWithOptions(opts ...Option) (*Server, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	// compile responses before listening, so that invalid responses panic without leaking the port
+	server := New(o.responses...)
+	server.Logger = o.logger
+	server.UnknownRequestHandler = o.unknownRequestHandler
+
+	var listener net.Listener
+	if o.addr != "" {
+		l, err := net.Listen("tcp", o.addr)
+		if err != nil {
+			return nil, err
+		}
+		listener = l
+	}
+
+	httptestserver := httptest.NewUnstartedServer(server.Handler())
+	if listener != nil {
+		httptestserver.Listener.Close()
+		httptestserver.Listener = listener
+	}
+	httptestserver.EnableHTTP2 = o.http2
+	if o.tls {
+		httptestserver.StartTLS()
+	} else {
+		httptestserver.Start()
+	}
+
+	server.Server = httptestserver
+	server.URL = httptestserver.URL
+
+	return server, nil
+}
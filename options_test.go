@@ -0,0 +1,122 @@
+package httpmocker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLaunchWithOptions(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		server, err := LaunchWithOptions(
+			WithResponses(Response{Method: "GET", Path: "/hello", Code: http.StatusOK, Body: "hello, world"}),
+			WithLogger(t),
+			WithUnknownRequestHandler(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			}),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		defer server.Close()
+
+		if !strings.HasPrefix(server.URL, "http://") {
+			t.Errorf("URL should start with http:// : actual %s", server.URL)
+		}
+
+		resp, err := http.Get(fmt.Sprintf("%s/hello", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if string(body) != "hello, world" {
+			t.Errorf("response body should be \"hello, world\" : actual %s", body)
+		}
+
+		resp, err = http.Get(fmt.Sprintf("%s/unknown", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusTeapot {
+			t.Errorf("status code should be 418 : actual %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("tls on fixed address", func(t *testing.T) {
+		// find a free port
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		addr := l.Addr().String()
+		l.Close()
+
+		server, err := LaunchWithOptions(
+			WithResponses(Response{Method: "GET", Path: "/hello", Code: http.StatusOK}),
+			WithTLS(),
+			WithAddr(addr),
+			WithLogger(t),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		defer server.Close()
+
+		if server.URL != "https://"+addr {
+			t.Errorf("URL should be https://%s : actual %s", addr, server.URL)
+		}
+
+		resp, err := server.Client().Get(fmt.Sprintf("%s/hello", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("status code should be 200 : actual %d", resp.StatusCode)
+		}
+
+		if _, err := LaunchWithOptions(WithAddr(addr)); err == nil {
+			t.Errorf("LaunchWithOptions should fail if the address is already in use")
+		}
+	})
+
+	t.Run("invalid response on fixed address", func(t *testing.T) {
+		// find a free port
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		addr := l.Addr().String()
+		l.Close()
+
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("LaunchWithOptions should panic if the response is invalid")
+				}
+			}()
+			LaunchWithOptions(
+				WithResponses(Response{Method: "GET", Path: "/[", PathRegex: true}),
+				WithAddr(addr),
+			)
+		}()
+
+		// the port should not be leaked
+		l, err = net.Listen("tcp", addr)
+		if err != nil {
+			t.Fatalf("address should be available after panic : %+v", err)
+		}
+		l.Close()
+	})
+}
@@ -0,0 +1,47 @@
+package httpmocker
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+// ProxyTo : forward unknown requests to given upstream when UnknownRequestHandler is not set
+func (server *Server) ProxyTo(baseURL string) *Server {
+	target, err := url.Parse(baseURL)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		panic(fmt.Sprintf("httpmocker: invalid proxy URL %q", baseURL))
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		r.Host = target.Host
+	}
+
+	server.responsesMu.Lock()
+	defer server.responsesMu.Unlock()
+
+	server.proxyTarget = target
+	server.proxy = proxy
+
+	return server
+}
+
+// handleProxy : forwards given request to the upstream, returns false if proxy is not configured
+func (server *Server) handleProxy(w http.ResponseWriter, r *http.Request) bool {
+	server.responsesMu.RLock()
+	target, proxy := server.proxyTarget, server.proxy
+	server.responsesMu.RUnlock()
+
+	if proxy == nil {
+		return false
+	}
+
+	server.infof("proxy : %s %s -> %s", r.Method, r.URL.Path, target)
+	proxy.ServeHTTP(w, r)
+
+	return true
+}
@@ -0,0 +1,139 @@
+package httpmocker
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestProxyTo(t *testing.T) {
+	upstream := Launch(
+		Response{
+			Method: "POST",
+			Path:   "/upstream",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Upstream", "true")
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprintf(w, "%s %s %s ", r.Method, r.URL.RawQuery, r.Header.Get("X-Custom-Header"))
+				io.Copy(w, r.Body)
+			},
+		},
+	)
+	upstream.Logger = t
+	defer upstream.Close()
+
+	server := Launch().Add("POST", "/mocked", http.StatusOK, "mocked").ProxyTo(upstream.URL)
+	server.Logger = t
+	defer server.Close()
+
+	post := func(path string) (*http.Response, string) {
+		req, err := http.NewRequest("POST", server.URL+path, strings.NewReader("body"))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		req.Header.Set("X-Custom-Header", "custom")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		return resp, string(body)
+	}
+
+	if _, body := post("/mocked"); body != "mocked" {
+		t.Errorf("response body should be \"mocked\": actual %s", body)
+	}
+
+	resp, body := post("/upstream?q=1")
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("status code should be 201 Created : actual %d", resp.StatusCode)
+	}
+
+	if resp.Header.Get("X-Upstream") != "true" {
+		t.Errorf("upstream response headers should be copied")
+	}
+
+	if body != "POST q=1 custom body" {
+		t.Errorf("method, headers and body should be forwarded: actual %s", body)
+	}
+}
+
+func TestProxyToWithOtherMethodMocked(t *testing.T) {
+	upstream := Launch().Add("POST", "/users", http.StatusCreated, "created by upstream")
+	upstream.Logger = t
+	defer upstream.Close()
+
+	server := Launch().Add("GET", "/users", http.StatusOK, "mocked").ProxyTo(upstream.URL)
+	server.Logger = t
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/users", "text/plain", strings.NewReader("body"))
+	if err != nil {
+		t.Fatalf("unexpected error : %+v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("unexpected error : %+v", err)
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("status code should be 201 Created : actual %d", resp.StatusCode)
+	}
+
+	if string(body) != "created by upstream" {
+		t.Errorf("request should be forwarded instead of 405: actual %s", body)
+	}
+}
+
+func TestProxyToWhileServing(t *testing.T) {
+	upstream := Launch().Add("GET", "/upstream", http.StatusOK, "upstream")
+	upstream.Logger = t
+	defer upstream.Close()
+
+	server := Launch()
+	server.Logger = t
+	defer server.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			resp, err := http.Get(server.URL + "/upstream")
+			if err != nil {
+				t.Errorf("unexpected error : %+v", err)
+				return
+			}
+			resp.Body.Close()
+		}
+	}()
+
+	server.ProxyTo(upstream.URL)
+	<-done
+
+	resp, err := http.Get(server.URL + "/upstream")
+	if err != nil {
+		t.Fatalf("unexpected error : %+v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("unexpected error : %+v", err)
+	}
+
+	if string(body) != "upstream" {
+		t.Errorf("request should be forwarded after ProxyTo: actual %s", body)
+	}
+}
@@ -0,0 +1,89 @@
+package httpmocker
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// Seed : seed the random source used for DelayMin/DelayMax, RandomCodes, Weight and other random behaviors, for reproducible tests
+func (server *Server) Seed(seed int64) *Server {
+	server.randMu.Lock()
+	defer server.randMu.Unlock()
+
+	server.rand = rand.New(rand.NewSource(seed))
+	return server
+}
+
+// randInt63n : returns a random number in [0, n) from the random source of the server
+func (server *Server) randInt63n(n int64) int64 {
+	server.randMu.Lock()
+	defer server.randMu.Unlock()
+
+	if server.rand == nil {
+		server.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	return server.rand.Int63n(n)
+}
+
+// delay : returns the duration to wait before responding.
+// If DelayMax is set, a random duration in [DelayMin, DelayMax] is used instead of Delay.
+func (server *Server) delay(resp *Response) time.Duration {
+	if resp.DelayMax <= 0 || resp.DelayMax < resp.DelayMin {
+		return resp.Delay
+	}
+
+	return resp.DelayMin + time.Duration(server.randInt63n(int64(resp.DelayMax-resp.DelayMin)+1))
+}
+
+// code : returns the status code of given response, choosing one of RandomCodes at random if set.
+// Zero Code is treated as 200 OK.
+func (server *Server) code(resp *Response) int {
+	if len(resp.RandomCodes) == 0 {
+		if resp.Code == 0 {
+			return http.StatusOK
+		}
+		return resp.Code
+	}
+
+	return resp.RandomCodes[server.randInt63n(int64(len(resp.RandomCodes)))]
+}
+
+// weighted : returns one of given responses chosen at random in proportion to Weight,
+// or nil if none of them has Weight
+func (server *Server) weighted(resps []*Response) *Response {
+	total := 0
+	hasWeight := false
+	for _, resp := range resps {
+		if resp.Weight > 0 {
+			hasWeight = true
+		}
+		total += resp.weight()
+	}
+
+	if !hasWeight {
+		return nil
+	}
+	if len(resps) == 1 {
+		return resps[0]
+	}
+
+	n := int(server.randInt63n(int64(total)))
+	for _, resp := range resps {
+		if n -= resp.weight(); n < 0 {
+			return resp
+		}
+	}
+
+	return resps[len(resps)-1]
+}
+
+// weight : returns Weight, or 1 if Weight is not set
+func (resp *Response) weight() int {
+	if resp.Weight > 0 {
+		return resp.Weight
+	}
+
+	return 1
+}
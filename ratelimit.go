@@ -0,0 +1,80 @@
+package httpmocker
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// rateLimit : sliding window rate limit for a method and path
+type rateLimit struct {
+	limit      int
+	window     time.Duration
+	timestamps []time.Time
+}
+
+// AddRateLimit : limit mock responses registered with given method and path to limit requests per window.
+// Requests over the limit get 429 Too Many Requests with Retry-After header.
+func (server *Server) AddRateLimit(method, path string, limit int, window time.Duration) *Server {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if server.rateLimits == nil {
+		server.rateLimits = map[string]*rateLimit{}
+	}
+	server.rateLimits[callKey(method, path)] = &rateLimit{limit: limit, window: window}
+
+	return server
+}
+
+// checkRateLimit : records the request to given response, and returns the duration to wait if the limit is exceeded
+func (server *Server) checkRateLimit(resp *Response) (time.Duration, bool) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	rl := server.rateLimits[callKey(resp.Method, resp.Path)]
+	if rl == nil {
+		return 0, true
+	}
+
+	now := time.Now()
+	timestamps := rl.timestamps[:0]
+	for _, ts := range rl.timestamps {
+		if now.Sub(ts) < rl.window {
+			timestamps = append(timestamps, ts)
+		}
+	}
+	rl.timestamps = timestamps
+
+	if len(rl.timestamps) >= rl.limit {
+		if len(rl.timestamps) == 0 {
+			return rl.window, false
+		}
+		return rl.timestamps[0].Add(rl.window).Sub(now), false
+	}
+
+	rl.timestamps = append(rl.timestamps, now)
+	return 0, true
+}
+
+// releaseRateLimit : cancels the last request recorded by checkRateLimit for given response
+func (server *Server) releaseRateLimit(resp *Response) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if rl := server.rateLimits[callKey(resp.Method, resp.Path)]; rl != nil && len(rl.timestamps) > 0 {
+		rl.timestamps = rl.timestamps[:len(rl.timestamps)-1]
+	}
+}
+
+// writeTooManyRequests : writes 429 Too Many Requests with Retry-After in seconds
+func writeTooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.WriteHeader(http.StatusTooManyRequests)
+}
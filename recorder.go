@@ -0,0 +1,158 @@
+package httpmocker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// RecordedRequest : request received by mock server
+type RecordedRequest struct {
+	Method string
+	Path   string
+	Query  string
+	Header http.Header
+	Body   []byte
+}
+
+// recordRequest : records given request, and buffers its body so that it can be read again
+func (server *Server) recordRequest(r *http.Request) error {
+	body, err := bufferBody(r)
+	if err != nil {
+		return err
+	}
+
+	recorded := RecordedRequest{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.RawQuery,
+		Header: cloneHeader(r.Header),
+		Body:   body,
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.requests = append(server.requests, recorded)
+	server.requestedCond().Broadcast()
+	return nil
+}
+
+// requestedCond : returns the condition variable broadcast when a request is recorded. mu must be held.
+func (server *Server) requestedCond() *sync.Cond {
+	if server.requested == nil {
+		server.requested = sync.NewCond(&server.mu)
+	}
+
+	return server.requested
+}
+
+// WaitForRequest : blocks until a request with given method and path has been received, or ctx is done.
+// Requests received before calling WaitForRequest are also considered.
+func (server *Server) WaitForRequest(ctx context.Context, method, path string) (*RecordedRequest, error) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	cond := server.requestedCond()
+
+	// wake up the waiter below when ctx is done
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			server.mu.Lock()
+			cond.Broadcast()
+			server.mu.Unlock()
+		case <-done:
+		}
+	}()
+
+	for {
+		for _, req := range server.requests {
+			if strings.EqualFold(req.Method, method) && req.Path == path {
+				found := req
+				return &found, nil
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("httpmocker: %s %s was not requested : %w", method, path, err)
+		}
+
+		cond.Wait()
+	}
+}
+
+// bufferBody : reads request body, and restores it so that it can be read again
+func bufferBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return body, err
+}
+
+// ReceivedRequests : returns requests received by mock server in order
+func (server *Server) ReceivedRequests() []RecordedRequest {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	requests := make([]RecordedRequest, len(server.requests))
+	copy(requests, server.requests)
+	return requests
+}
+
+// ReceivedBodies : returns copies of the bodies of requests received with given method and path in order
+func (server *Server) ReceivedBodies(method, path string) [][]byte {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	var bodies [][]byte
+	for _, req := range server.requests {
+		if strings.EqualFold(req.Method, method) && req.Path == path {
+			bodies = append(bodies, append([]byte(nil), req.Body...))
+		}
+	}
+
+	return bodies
+}
+
+// Reset : clears recorded requests, call counts, durations, rate limit windows and responses cached for idempotency, rewinds sequences,
+// and makes responses with Once available again
+func (server *Server) Reset() {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.requests = nil
+	server.callCounts = nil
+	server.totalRequests = 0
+	server.durations = nil
+	server.lastDuration = 0
+	server.unmatched = nil
+	server.sequenceIndexes = nil
+	server.consumed = nil
+	if server.idempotency != nil {
+		server.idempotency.entries = map[string]*idempotentEntry{}
+	}
+	for _, rl := range server.rateLimits {
+		rl.timestamps = nil
+	}
+}
+
+func cloneHeader(header http.Header) http.Header {
+	cloned := make(http.Header, len(header))
+	for k, values := range header {
+		cloned[k] = append([]string(nil), values...)
+	}
+
+	return cloned
+}
@@ -0,0 +1,308 @@
+package httpmocker
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// schema : JSON Schema used by RequestSchema. Supported keywords are type, enum, properties, required,
+// additionalProperties, items, minimum, maximum, minLength, maxLength, pattern, minItems and maxItems.
+// Other keywords such as $ref and oneOf are rejected, so that invalid bodies never pass silently.
+type schema struct {
+	Type                 schemaTypes        `json:"type"`
+	Enum                 []interface{}      `json:"enum"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *additionalSchema  `json:"additionalProperties"`
+	Items                *schema            `json:"items"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Pattern              string             `json:"pattern"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+
+	pattern *regexp.Regexp
+}
+
+// schemaTypes : value of type keyword, which is a string or an array of strings
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = schemaTypes{s}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*t = list
+
+	return nil
+}
+
+// additionalSchema : value of additionalProperties keyword, which is a boolean or a schema
+type additionalSchema struct {
+	allowed bool
+	schema  *schema
+}
+
+func (a *additionalSchema) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &a.allowed); err == nil {
+		return nil
+	}
+
+	a.allowed = true
+	return json.Unmarshal(data, &a.schema)
+}
+
+// schemaKeywords : keywords validated by schema, and annotations which do not affect validation
+var schemaKeywords = map[string]bool{
+	"type": true, "enum": true, "properties": true, "required": true, "additionalProperties": true, "items": true,
+	"minimum": true, "maximum": true, "minLength": true, "maxLength": true, "pattern": true, "minItems": true, "maxItems": true,
+	"$schema": true, "$id": true, "$comment": true, "title": true, "description": true, "default": true, "examples": true,
+}
+
+// checkKeywords : returns error if given JSON Schema or its subschemas use unsupported keywords
+func checkKeywords(data []byte, path string) error {
+	var keywords map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keywords); err != nil {
+		// not an object, such as boolean additionalProperties
+		return nil
+	}
+
+	names := make([]string, 0, len(keywords))
+	for name := range keywords {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !schemaKeywords[name] {
+			return fmt.Errorf("unsupported keyword %q at %s", name, path)
+		}
+	}
+
+	var properties map[string]json.RawMessage
+	if raw, ok := keywords["properties"]; ok {
+		if err := json.Unmarshal(raw, &properties); err != nil {
+			return err
+		}
+	}
+	names = names[:0]
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := checkKeywords(properties[name], path+".properties."+name); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range []string{"items", "additionalProperties"} {
+		if raw, ok := keywords[name]; ok {
+			if err := checkKeywords(raw, path+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// mustParseSchema : parses given JSON Schema, panics if it is invalid
+func mustParseSchema(s string) *schema {
+	if err := checkKeywords([]byte(s), "$"); err != nil {
+		panic(fmt.Sprintf("httpmocker: invalid RequestSchema %q : %v", s, err))
+	}
+
+	var sc schema
+	if err := json.Unmarshal([]byte(s), &sc); err != nil {
+		panic(fmt.Sprintf("httpmocker: invalid RequestSchema %q : %v", s, err))
+	}
+
+	if err := sc.compile(); err != nil {
+		panic(fmt.Sprintf("httpmocker: invalid RequestSchema %q : %v", s, err))
+	}
+
+	return &sc
+}
+
+// compile : compiles patterns of the schema and its subschemas
+func (sc *schema) compile() error {
+	if sc.Pattern != "" {
+		re, err := regexp.Compile(sc.Pattern)
+		if err != nil {
+			return err
+		}
+		sc.pattern = re
+	}
+
+	for _, prop := range sc.Properties {
+		if err := prop.compile(); err != nil {
+			return err
+		}
+	}
+
+	if sc.AdditionalProperties != nil && sc.AdditionalProperties.schema != nil {
+		if err := sc.AdditionalProperties.schema.compile(); err != nil {
+			return err
+		}
+	}
+
+	if sc.Items != nil {
+		return sc.Items.compile()
+	}
+
+	return nil
+}
+
+// validateJSON : returns validation errors of given JSON document
+func (sc *schema) validateJSON(body []byte) []string {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return []string{fmt.Sprintf("invalid JSON : %v", err)}
+	}
+
+	return sc.validate(v, "$")
+}
+
+// validate : returns validation errors of given value at given path such as "$.items[0]"
+func (sc *schema) validate(v interface{}, path string) []string {
+	if len(sc.Type) > 0 && !sc.matchType(v) {
+		return []string{fmt.Sprintf("%s : expected %s, got %s", path, strings.Join(sc.Type, " or "), jsonType(v))}
+	}
+
+	var errs []string
+	if len(sc.Enum) > 0 && !sc.inEnum(v) {
+		errs = append(errs, fmt.Sprintf("%s : must be one of the enum values", path))
+	}
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for _, name := range sc.Required {
+			if _, ok := value[name]; !ok {
+				errs = append(errs, fmt.Sprintf("%s : missing required property %q", path, name))
+			}
+		}
+
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			prop, ok := sc.Properties[name]
+			switch {
+			case ok:
+				errs = append(errs, prop.validate(value[name], path+"."+name)...)
+			case sc.AdditionalProperties == nil:
+			case sc.AdditionalProperties.schema != nil:
+				errs = append(errs, sc.AdditionalProperties.schema.validate(value[name], path+"."+name)...)
+			case !sc.AdditionalProperties.allowed:
+				errs = append(errs, fmt.Sprintf("%s : unexpected property %q", path, name))
+			}
+		}
+
+	case []interface{}:
+		if sc.MinItems != nil && len(value) < *sc.MinItems {
+			errs = append(errs, fmt.Sprintf("%s : must have at least %d items", path, *sc.MinItems))
+		}
+		if sc.MaxItems != nil && len(value) > *sc.MaxItems {
+			errs = append(errs, fmt.Sprintf("%s : must have at most %d items", path, *sc.MaxItems))
+		}
+		if sc.Items != nil {
+			for i, item := range value {
+				errs = append(errs, sc.Items.validate(item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+
+	case string:
+		n := len([]rune(value))
+		if sc.MinLength != nil && n < *sc.MinLength {
+			errs = append(errs, fmt.Sprintf("%s : must be at least %d characters", path, *sc.MinLength))
+		}
+		if sc.MaxLength != nil && n > *sc.MaxLength {
+			errs = append(errs, fmt.Sprintf("%s : must be at most %d characters", path, *sc.MaxLength))
+		}
+		if sc.pattern != nil && !sc.pattern.MatchString(value) {
+			errs = append(errs, fmt.Sprintf("%s : must match pattern %q", path, sc.Pattern))
+		}
+
+	case float64:
+		if sc.Minimum != nil && value < *sc.Minimum {
+			errs = append(errs, fmt.Sprintf("%s : must be >= %v", path, *sc.Minimum))
+		}
+		if sc.Maximum != nil && value > *sc.Maximum {
+			errs = append(errs, fmt.Sprintf("%s : must be <= %v", path, *sc.Maximum))
+		}
+	}
+
+	return errs
+}
+
+// matchType : returns true if given value is one of Type
+func (sc *schema) matchType(v interface{}) bool {
+	actual := jsonType(v)
+	for _, t := range sc.Type {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (sc *schema) inEnum(v interface{}) bool {
+	for _, e := range sc.Enum {
+		if containsJSON(v, e) && containsJSON(e, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// jsonType : returns JSON Schema type name of given decoded JSON value
+func jsonType(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if value == math.Trunc(value) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// writeSchemaErrors : writes 400 Bad Request with validation errors such as {"errors":["$.name : ..."]}
+func writeSchemaErrors(w http.ResponseWriter, errs []string) {
+	body, _ := json.Marshal(map[string][]string{"errors": errs})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write(body)
+}
@@ -0,0 +1,149 @@
+package httpmocker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRequestSchema(t *testing.T) {
+	server := Launch(
+		Response{
+			Method: "POST",
+			Path:   "/users",
+			Code:   http.StatusCreated,
+			Body:   "created",
+			RequestSchema: `{
+				"type": "object",
+				"required": ["name", "age"],
+				"additionalProperties": false,
+				"properties": {
+					"name": {"type": "string", "minLength": 1, "pattern": "^[a-z]+$"},
+					"age": {"type": "integer", "minimum": 0},
+					"role": {"enum": ["admin", "member"]},
+					"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2}
+				}
+			}`,
+		},
+	)
+	server.Logger = t
+	defer server.Close()
+
+	for body, expected := range map[string]struct {
+		code   int
+		errors []string
+	}{
+		`{"name": "alice", "age": 20, "role": "admin", "tags": ["a", "b"]}`: {http.StatusCreated, nil},
+		`{"name": "Alice", "age": 1.5}`: {http.StatusBadRequest, []string{
+			`$.age : expected integer, got number`,
+			`$.name : must match pattern "^[a-z]+$"`,
+		}},
+		`{"age": -1, "role": "guest", "tags": ["a", 1, "c"], "extra": true}`: {http.StatusBadRequest, []string{
+			`$ : missing required property "name"`,
+			`$.age : must be >= 0`,
+			`$ : unexpected property "extra"`,
+			`$.role : must be one of the enum values`,
+			`$.tags : must have at most 2 items`,
+			`$.tags[1] : expected string, got integer`,
+		}},
+		`[]`:       {http.StatusBadRequest, []string{`$ : expected object, got array`}},
+		`not json`: {http.StatusBadRequest, nil},
+	} {
+		resp, err := http.Post(fmt.Sprintf("%s/users", server.URL), "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		actual := drainBody(t, resp)
+
+		if resp.StatusCode != expected.code {
+			t.Errorf("status code for %s should be %d: actual %d", body, expected.code, resp.StatusCode)
+		}
+
+		if expected.errors == nil {
+			continue
+		}
+
+		var result struct {
+			Errors []string `json:"errors"`
+		}
+		if err := json.Unmarshal([]byte(actual), &result); err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if strings.Join(result.Errors, "\n") != strings.Join(expected.errors, "\n") {
+			t.Errorf("validation errors for %s should be %q: actual %q", body, expected.errors, result.Errors)
+		}
+	}
+}
+
+func TestMustParseSchema(t *testing.T) {
+	for _, s := range []string{
+		`{"$ref": "#/definitions/user"}`,
+		`{"oneOf": [{"type": "string"}, {"type": "integer"}]}`,
+		`{"type": "object", "properties": {"email": {"type": "string", "format": "email"}}}`,
+		`{"type": "array", "items": {"const": 1}}`,
+		`{"type": "object", "additionalProperties": {"not": {"type": "null"}}}`,
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "unsupported keyword") {
+					t.Errorf("schema %s should be rejected as unsupported: actual %v", s, r)
+				}
+			}()
+			mustParseSchema(s)
+		}()
+	}
+}
+
+func TestSchemaAdditionalProperties(t *testing.T) {
+	sc := mustParseSchema(`{
+		"type": "object",
+		"title": "labels",
+		"properties": {"name": {"type": "string"}},
+		"additionalProperties": {"type": "integer", "minimum": 0}
+	}`)
+
+	errs := sc.validateJSON([]byte(`{"name": "a", "x": 1, "y": "2", "z": -1}`))
+	expected := []string{
+		`$.y : expected integer, got string`,
+		`$.z : must be >= 0`,
+	}
+	if !reflect.DeepEqual(errs, expected) {
+		t.Errorf("validation errors should be %q: actual %q", expected, errs)
+	}
+}
+
+func TestRequestSchemaRejected(t *testing.T) {
+	server := Launch().AddSequence("POST", "/users",
+		Response{Code: http.StatusCreated, Body: "first", RequestSchema: `{"type": "object", "required": ["name"]}`},
+		Response{Code: http.StatusCreated, Body: "second"},
+	)
+	server.Logger = t
+	defer server.Close()
+
+	post := func(body string) *http.Response {
+		resp, err := http.Post(fmt.Sprintf("%s/users", server.URL), "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		drainBody(t, resp)
+
+		return resp
+	}
+
+	if resp := post(`{}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status code should be 400 Bad Request: actual %d", resp.StatusCode)
+	}
+
+	if count := server.CallCount("POST", "/users"); count != 0 {
+		t.Errorf("invalid request should not be counted: actual %d", count)
+	}
+
+	// the rejected request should not advance the sequence
+	if resp := post(`{"name": "alice"}`); resp.StatusCode != http.StatusCreated {
+		t.Errorf("status code should be 201 Created: actual %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,82 @@
+package httpmocker
+
+// AddSequence : add mock responses returned in order on successive requests.
+// Once the sequence is exhausted, the last response is returned repeatedly.
+func (server *Server) AddSequence(method, path string, responses ...Response) *Server {
+	return server.AddResponses(Response{
+		Method:   method,
+		Path:     path,
+		sequence: sequenceOf(method, path, responses),
+	})
+}
+
+// AddRoundRobin : add mock responses returned in order on successive requests.
+// Unlike AddSequence, it wraps back to the first response after the last one.
+func (server *Server) AddRoundRobin(method, path string, responses ...Response) *Server {
+	return server.AddResponses(Response{
+		Method:     method,
+		Path:       path,
+		sequence:   sequenceOf(method, path, responses),
+		roundRobin: true,
+	})
+}
+
+func sequenceOf(method, path string, responses []Response) []*Response {
+	sequence := make([]*Response, len(responses))
+	for i, response := range responses {
+		r := response
+		r.Method = method
+		r.Path = path
+		sequence[i] = compileResponse(r)
+	}
+
+	return sequence
+}
+
+// AddFlaky : add mock response which returns failCode for the first failN requests, then successCode with successBody.
+// The counter is rewound by Reset.
+func (server *Server) AddFlaky(method, path string, failCode, failN, successCode int, successBody string) *Server {
+	responses := make([]Response, 0, failN+1)
+	for i := 0; i < failN; i++ {
+		responses = append(responses, Response{Code: failCode})
+	}
+	responses = append(responses, Response{Code: successCode, Body: successBody})
+
+	return server.AddSequence(method, path, responses...)
+}
+
+// currentInSequence : returns current response of the sequence without advancing its index
+func (server *Server) currentInSequence(resp *Response) *Response {
+	if len(resp.sequence) == 0 {
+		return resp
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	return resp.sequence[server.sequenceIndexes[resp]]
+}
+
+// nextInSequence : returns current response of the sequence and advances its index
+func (server *Server) nextInSequence(resp *Response) *Response {
+	if resp == nil || len(resp.sequence) == 0 {
+		return resp
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if server.sequenceIndexes == nil {
+		server.sequenceIndexes = map[*Response]int{}
+	}
+
+	i := server.sequenceIndexes[resp]
+	switch {
+	case i < len(resp.sequence)-1:
+		server.sequenceIndexes[resp] = i + 1
+	case resp.roundRobin:
+		server.sequenceIndexes[resp] = 0
+	}
+
+	return resp.sequence[i]
+}
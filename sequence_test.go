@@ -0,0 +1,121 @@
+package httpmocker
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAddSequence(t *testing.T) {
+	server := Launch().AddSequence("GET", "/job",
+		Response{Code: http.StatusAccepted, Body: "pending"},
+		Response{Code: http.StatusAccepted, Body: "pending"},
+		Response{Code: http.StatusOK, Body: "complete"},
+	)
+	server.Logger = t
+	defer server.Close()
+
+	get := func() string {
+		resp, err := http.Get(fmt.Sprintf("%s/job", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		return drainBody(t, resp)
+	}
+
+	// the last response should be returned once the sequence is exhausted
+	for i, expected := range []string{"pending", "pending", "complete", "complete"} {
+		if body := get(); body != expected {
+			t.Errorf("response body of request %d should be %q: actual %s", i, expected, body)
+		}
+	}
+
+	server.Reset()
+	if body := get(); body != "pending" {
+		t.Errorf("sequence should be rewound by Reset: actual %s", body)
+	}
+}
+
+func TestAddSequenceCompiled(t *testing.T) {
+	server := Launch().
+		AddSequence("GET", "/template",
+			Response{BodyTemplate: "hello {{.Method}} {{.Path}}"},
+		).
+		AddRoundRobin("POST", "/users",
+			Response{Code: http.StatusCreated, RequestSchema: `{"type": "object", "required": ["name"]}`},
+		)
+	server.Logger = t
+	defer server.Close()
+
+	resp, err := http.Get(fmt.Sprintf("%s/template", server.URL))
+	if err != nil {
+		t.Fatalf("unexpected error : %+v", err)
+	}
+
+	if body := drainBody(t, resp); body != "hello GET /template" {
+		t.Errorf("response body should be rendered from BodyTemplate: actual %s", body)
+	}
+
+	resp, err = http.Post(fmt.Sprintf("%s/users", server.URL), "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatalf("unexpected error : %+v", err)
+	}
+	drainBody(t, resp)
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status code should be 400 Bad Request by RequestSchema: actual %d", resp.StatusCode)
+	}
+}
+
+func TestAddFlaky(t *testing.T) {
+	server := Launch().AddFlaky("GET", "/flaky", http.StatusServiceUnavailable, 2, http.StatusOK, "ok")
+	server.Logger = t
+	defer server.Close()
+
+	get := func() int {
+		resp, err := http.Get(fmt.Sprintf("%s/flaky", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		drainBody(t, resp)
+
+		return resp.StatusCode
+	}
+
+	expected := []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK, http.StatusOK}
+	for i, code := range expected {
+		if actual := get(); actual != code {
+			t.Errorf("status code of request %d should be %d: actual %d", i, code, actual)
+		}
+	}
+
+	server.Reset()
+	if actual := get(); actual != http.StatusServiceUnavailable {
+		t.Errorf("status code should be 503 after Reset: actual %d", actual)
+	}
+}
+
+func TestAddRoundRobin(t *testing.T) {
+	server := Launch().AddRoundRobin("GET", "/backend",
+		Response{Code: http.StatusOK, Body: "a"},
+		Response{Code: http.StatusOK, Body: "b"},
+		Response{Code: http.StatusOK, Body: "c"},
+	)
+	server.Logger = t
+	defer server.Close()
+
+	var bodies []string
+	for i := 0; i < 7; i++ {
+		resp, err := http.Get(fmt.Sprintf("%s/backend", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		bodies = append(bodies, drainBody(t, resp))
+	}
+
+	if actual := strings.Join(bodies, ""); actual != "abcabca" {
+		t.Errorf("responses should cycle as \"abcabca\": actual %s", actual)
+	}
+}
@@ -0,0 +1,138 @@
+package httpmocker
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Snapshot : state of mock server captured by Server.Snapshot
+type Snapshot struct {
+	responses   map[string]map[string][]*Response
+	defaultResp *Response
+	mirrorHEAD  bool
+	autoOptions bool
+	proxyTarget *url.URL
+	proxy       http.Handler
+
+	callCounts      map[string]int
+	totalRequests   int
+	unmatched       []string
+	requests        []RecordedRequest
+	sequenceIndexes map[*Response]int
+	consumed        map[*Response]bool
+	durations       map[string]*durationStat
+	lastDuration    time.Duration
+	rateLimits      map[string]*rateLimit
+}
+
+// Snapshot : captures registered mock responses, the default response, MirrorHEAD, AutoOptions, ProxyTo and rate limits,
+// and recorded requests and counters so that they can be restored later.
+// Exported fields of Server such as Logger and hooks, DisableKeepAlives and responses cached by EnableIdempotency are not captured.
+func (server *Server) Snapshot() *Snapshot {
+	server.responsesMu.RLock()
+	s := &Snapshot{
+		responses:   copyResponses(server.Responses),
+		defaultResp: server.defaultResp,
+		mirrorHEAD:  server.mirrorHEAD,
+		autoOptions: server.autoOptions,
+		proxyTarget: server.proxyTarget,
+		proxy:       server.proxy,
+	}
+	server.responsesMu.RUnlock()
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	s.callCounts = copyCounts(server.callCounts)
+	s.totalRequests = server.totalRequests
+	s.unmatched = append([]string(nil), server.unmatched...)
+	s.requests = append([]RecordedRequest(nil), server.requests...)
+	s.sequenceIndexes = make(map[*Response]int, len(server.sequenceIndexes))
+	for resp, i := range server.sequenceIndexes {
+		s.sequenceIndexes[resp] = i
+	}
+	s.consumed = make(map[*Response]bool, len(server.consumed))
+	for resp := range server.consumed {
+		s.consumed[resp] = true
+	}
+	s.durations = copyDurations(server.durations)
+	s.lastDuration = server.lastDuration
+	s.rateLimits = copyRateLimits(server.rateLimits)
+
+	return s
+}
+
+// Restore : reverts registered mock responses and counters to given snapshot
+func (server *Server) Restore(s *Snapshot) *Server {
+	server.responsesMu.Lock()
+	server.Responses = copyResponses(s.responses)
+	server.defaultResp = s.defaultResp
+	server.mirrorHEAD = s.mirrorHEAD
+	server.autoOptions = s.autoOptions
+	server.proxyTarget = s.proxyTarget
+	server.proxy = s.proxy
+	server.responsesMu.Unlock()
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.callCounts = copyCounts(s.callCounts)
+	server.totalRequests = s.totalRequests
+	server.unmatched = append([]string(nil), s.unmatched...)
+	server.requests = append([]RecordedRequest(nil), s.requests...)
+	server.sequenceIndexes = make(map[*Response]int, len(s.sequenceIndexes))
+	for resp, i := range s.sequenceIndexes {
+		server.sequenceIndexes[resp] = i
+	}
+	server.consumed = make(map[*Response]bool, len(s.consumed))
+	for resp := range s.consumed {
+		server.consumed[resp] = true
+	}
+	server.durations = copyDurations(s.durations)
+	server.lastDuration = s.lastDuration
+	server.rateLimits = copyRateLimits(s.rateLimits)
+
+	return server
+}
+
+// copyResponses : copies given responses so that adding or removing responses does not affect the copy
+func copyResponses(responses map[string]map[string][]*Response) map[string]map[string][]*Response {
+	copied := make(map[string]map[string][]*Response, len(responses))
+	for method, m := range responses {
+		cm := make(map[string][]*Response, len(m))
+		for path, resps := range m {
+			cm[path] = append([]*Response(nil), resps...)
+		}
+		copied[method] = cm
+	}
+
+	return copied
+}
+
+func copyCounts(counts map[string]int) map[string]int {
+	copied := make(map[string]int, len(counts))
+	for k, v := range counts {
+		copied[k] = v
+	}
+
+	return copied
+}
+
+func copyDurations(durations map[string]*durationStat) map[string]*durationStat {
+	copied := make(map[string]*durationStat, len(durations))
+	for k, stat := range durations {
+		copied[k] = &durationStat{total: stat.total, count: stat.count}
+	}
+
+	return copied
+}
+
+func copyRateLimits(rateLimits map[string]*rateLimit) map[string]*rateLimit {
+	copied := make(map[string]*rateLimit, len(rateLimits))
+	for k, rl := range rateLimits {
+		copied[k] = &rateLimit{limit: rl.limit, window: rl.window, timestamps: append([]time.Time(nil), rl.timestamps...)}
+	}
+
+	return copied
+}
@@ -0,0 +1,57 @@
+package httpmocker
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// statusLine : returns Status with the status code prepended unless it starts with one
+func (resp *Response) statusLine(code int) string {
+	if fields := strings.Fields(resp.Status); len(fields) > 0 {
+		if _, err := strconv.Atoi(fields[0]); err == nil {
+			return resp.Status
+		}
+	}
+
+	return fmt.Sprintf("%d %s", code, resp.Status)
+}
+
+// writeRawResponse : hijacks the connection and writes the response with the status line of given response,
+// since http.ResponseWriter always writes the standard reason phrase.
+// Chunks, Trickle and Trailers are ignored, and the body is written at once.
+// The connection is closed afterwards, since a hijacked connection cannot be returned to the server.
+// It returns false if hijacking is not supported, such as HTTP/2.
+func (server *Server) writeRawResponse(w http.ResponseWriter, r *http.Request, resp *Response, code int, body []byte) bool {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok || r.ProtoMajor != 1 {
+		server.warnf("failed to write custom status : hijacking is not supported by %T", w)
+		return false
+	}
+
+	header := w.Header()
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		server.warnf("failed to write custom status : %+v", err)
+		return false
+	}
+	defer conn.Close()
+
+	if len(body) > 0 && header.Get("Content-Length") == "" {
+		header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+	header.Set("Connection", "close")
+
+	fmt.Fprintf(buf, "HTTP/1.1 %s\r\n", resp.statusLine(code))
+	header.Write(buf)
+	buf.WriteString("\r\n")
+	if r.Method != "HEAD" {
+		buf.Write(body)
+	}
+	if err := buf.Flush(); err != nil {
+		server.warnf("failed to write custom status : %+v", err)
+	}
+
+	return true
+}
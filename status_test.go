@@ -0,0 +1,82 @@
+package httpmocker
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCustomStatus(t *testing.T) {
+	server := Launch(
+		Response{Method: "GET", Path: "/hello", Code: http.StatusOK, Status: "200 Custom OK", Body: "hello, world"},
+		Response{Method: "GET", Path: "/teapot", Code: http.StatusTeapot, Status: "Short and Stout"},
+		Response{Method: "GET", Path: "/standard", Code: http.StatusOK, Body: "standard"},
+	)
+	server.Logger = t
+	defer server.Close()
+
+	for path, expected := range map[string]struct {
+		status string
+		body   string
+	}{
+		"/hello":    {"200 Custom OK", "hello, world"},
+		"/teapot":   {"418 Short and Stout", ""},
+		"/standard": {"200 OK", "standard"},
+	} {
+		resp, err := http.Get(fmt.Sprintf("%s%s", server.URL, path))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if body := drainBody(t, resp); body != expected.body {
+			t.Errorf("response body of %s should be %q: actual %s", path, expected.body, body)
+		}
+
+		if resp.Status != expected.status {
+			t.Errorf("status of %s should be %q: actual %s", path, expected.status, resp.Status)
+		}
+	}
+}
+
+func TestCustomStatusWithoutBody(t *testing.T) {
+	server := Launch(
+		Response{
+			Method:   "GET",
+			Path:     "/empty",
+			Code:     http.StatusNoContent,
+			Status:   "204 Nothing Here",
+			Body:     "dropped",
+			Trailers: http.Header{"X-Checksum": {"abc"}},
+		},
+	)
+	server.Logger = t
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Addr())
+	if err != nil {
+		t.Fatalf("unexpected error : %+v", err)
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "GET /empty HTTP/1.1\r\nHost: %s\r\n\r\n", server.Addr())
+
+	var lines []string
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() && scanner.Text() != "" {
+		lines = append(lines, scanner.Text())
+	}
+
+	if len(lines) == 0 || lines[0] != "HTTP/1.1 204 Nothing Here" {
+		t.Fatalf("status line should be \"HTTP/1.1 204 Nothing Here\": actual %q", lines)
+	}
+
+	for _, line := range lines[1:] {
+		name := strings.SplitN(line, ":", 2)[0]
+		if name == "Content-Length" || name == "Trailer" {
+			t.Errorf("%s header should be omitted: actual %s", name, line)
+		}
+	}
+}
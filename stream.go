@@ -0,0 +1,85 @@
+package httpmocker
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// writeChunks : writes Chunks one by one, flushing after each chunk
+func (server *Server) writeChunks(w http.ResponseWriter, r *http.Request, resp *Response) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		server.warnf("%T does not implement http.Flusher, chunks are written at once", w)
+		io.WriteString(w, strings.Join(resp.Chunks, ""))
+		return
+	}
+
+	for i, chunk := range resp.Chunks {
+		if i > 0 && !sleep(r.Context(), resp.ChunkDelay) {
+			server.infof("request cancelled : %s %s", r.Method, r.URL.Path)
+			return
+		}
+
+		io.WriteString(w, chunk)
+		flusher.Flush()
+	}
+}
+
+// writeTrickle : writes body one byte at a time, waiting Trickle between bytes
+func (server *Server) writeTrickle(w http.ResponseWriter, r *http.Request, body []byte, resp *Response) {
+	flusher, _ := w.(http.Flusher)
+	for i := range body {
+		if i > 0 && !sleep(r.Context(), resp.Trickle) {
+			server.infof("request cancelled : %s %s", r.Method, r.URL.Path)
+			return
+		}
+
+		if _, err := w.Write(body[i : i+1]); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// AddSSE : add mock response for GET requests to given path which streams events as server-sent events,
+// waiting interval between events. Streaming stops when the client disconnects.
+func (server *Server) AddSSE(path string, events []string, interval time.Duration) *Server {
+	return server.AddResponses(Response{
+		Method: "GET",
+		Path:   path,
+		Handler: func(w http.ResponseWriter, r *http.Request) {
+			server.writeEvents(w, r, events, interval)
+		},
+	})
+}
+
+// writeEvents : writes events in text/event-stream format, flushing after each event
+func (server *Server) writeEvents(w http.ResponseWriter, r *http.Request, events []string, interval time.Duration) {
+	flusher, _ := w.(http.Flusher)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	for i, event := range events {
+		if i > 0 && !sleep(r.Context(), interval) {
+			server.infof("request cancelled : %s %s", r.Method, r.URL.Path)
+			return
+		}
+
+		// each line of multi-line data needs its own field
+		for _, line := range strings.Split(event, "\n") {
+			if _, err := io.WriteString(w, "data: "+line+"\n"); err != nil {
+				return
+			}
+		}
+		io.WriteString(w, "\n")
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
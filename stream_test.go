@@ -0,0 +1,85 @@
+package httpmocker
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAddSSE(t *testing.T) {
+	server := Launch().AddSSE("/events", []string{"hello", "multi\nline", "bye"}, 20*time.Millisecond)
+	server.Logger = t
+	defer server.Close()
+
+	resp, err := http.Get(fmt.Sprintf("%s/events", server.URL))
+	if err != nil {
+		t.Fatalf("unexpected error : %+v", err)
+	}
+	defer resp.Body.Close()
+
+	if ctype := resp.Header.Get("Content-Type"); ctype != "text/event-stream" {
+		t.Errorf("Content-Type should be \"text/event-stream\": actual %s", ctype)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("unexpected error : %+v", err)
+	}
+	if line != "data: hello\n" {
+		t.Errorf("first line should be \"data: hello\": actual %q", line)
+	}
+
+	rest, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("unexpected error : %+v", err)
+	}
+	if expected := "\ndata: multi\ndata: line\n\ndata: bye\n\n"; string(rest) != expected {
+		t.Errorf("rest of events should be %q: actual %q", expected, rest)
+	}
+}
+
+func TestAddSSEClientDisconnect(t *testing.T) {
+	server := Launch().AddSSE("/events", []string{"hello", "never sent"}, time.Minute)
+	server.Logger = t
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/events", server.URL), nil)
+	if err != nil {
+		t.Fatalf("unexpected error : %+v", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatalf("unexpected error : %+v", err)
+	}
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatalf("unexpected error : %+v", err)
+	}
+	if line != "data: hello\n" {
+		t.Errorf("first line should be \"data: hello\": actual %q", line)
+	}
+
+	cancel()
+	resp.Body.Close()
+
+	// Close blocks until the handler returns
+	done := make(chan struct{})
+	go func() {
+		server.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Errorf("handler should stop when client disconnects")
+	}
+}
@@ -0,0 +1,40 @@
+package httpmocker
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"text/template"
+)
+
+// TemplateData : data passed to BodyTemplate on execution
+type TemplateData struct {
+	Method     string
+	Path       string
+	PathParams map[string]string
+	Query      url.Values
+	Header     http.Header
+}
+
+// mustParseTemplate : parses given BodyTemplate, panics if it is invalid
+func mustParseTemplate(name, text string) *template.Template {
+	return template.Must(template.New(name).Option("missingkey=error").Parse(text))
+}
+
+// executeTemplate : executes BodyTemplate against given request
+func (resp *Response) executeTemplate(r *http.Request) ([]byte, error) {
+	data := TemplateData{
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		PathParams: PathParams(r),
+		Query:      r.URL.Query(),
+		Header:     r.Header,
+	}
+
+	var buf bytes.Buffer
+	if err := resp.bodyTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
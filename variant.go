@@ -0,0 +1,156 @@
+package httpmocker
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// compileVariants : prepares Variants to be served in place of the response
+func (resp *Response) compileVariants() map[string]*Response {
+	variants := make(map[string]*Response, len(resp.Variants))
+	for mediaType, variant := range resp.Variants {
+		v := variant
+		if v.ContentType == "" && v.JSONBody == nil && mediaType != "*/*" {
+			v.ContentType = mediaType
+		}
+		if v.BodyTemplate != "" {
+			v.bodyTemplate = mustParseTemplate(resp.Path, v.BodyTemplate)
+		}
+		variants[mediaType] = &v
+	}
+
+	return variants
+}
+
+// withVariant : returns a copy of the response whose body and content type are replaced by given variant.
+// Code and Status of the variant are used if set, and its Headers, Cookies and Trailers are added to those of the response.
+func (resp *Response) withVariant(v *Response) *Response {
+	r := resp.clone()
+	r.Variants = nil
+	r.variants = nil
+
+	r.ContentType = v.ContentType
+	r.Body = v.Body
+	r.BodyBytes = v.BodyBytes
+	r.BodyFile = v.BodyFile
+	r.JSONBody = v.JSONBody
+	r.BodyTemplate = v.BodyTemplate
+	r.bodyTemplate = v.bodyTemplate
+	r.Chunks = v.Chunks
+	r.Handler = v.Handler
+
+	if v.Code != 0 || len(v.RandomCodes) > 0 {
+		r.Code = v.Code
+		r.RandomCodes = v.RandomCodes
+	}
+	if v.Status != "" {
+		r.Status = v.Status
+	}
+	if v.Gzip {
+		r.Gzip = true
+	}
+
+	for k, values := range v.Headers {
+		if r.Headers == nil {
+			r.Headers = http.Header{}
+		}
+		r.Headers[k] = append([]string(nil), values...)
+	}
+	for k, values := range v.Trailers {
+		if r.Trailers == nil {
+			r.Trailers = http.Header{}
+		}
+		r.Trailers[k] = append([]string(nil), values...)
+	}
+	r.Cookies = append(r.Cookies, v.Cookies...)
+	r.ReflectHeaders = append(append([]string(nil), r.ReflectHeaders...), v.ReflectHeaders...)
+
+	return r
+}
+
+// variant : returns the variant most acceptable for Accept header of given request, or nil if none is acceptable
+func (resp *Response) variant(r *http.Request) *Response {
+	mediaTypes := make([]string, 0, len(resp.variants))
+	for mediaType := range resp.variants {
+		if mediaType != "*/*" {
+			mediaTypes = append(mediaTypes, mediaType)
+		}
+	}
+	sort.Strings(mediaTypes)
+
+	for _, rng := range acceptedMediaRanges(r) {
+		for _, mediaType := range mediaTypes {
+			if matchMediaRange(rng, mediaType) {
+				return resp.variants[mediaType]
+			}
+		}
+	}
+
+	return resp.variants["*/*"]
+}
+
+// acceptedMediaRanges : returns media ranges in Accept header of given request in order of preference
+func acceptedMediaRanges(r *http.Request) []string {
+	if len(r.Header["Accept"]) == 0 {
+		return []string{"*/*"}
+	}
+
+	type mediaRange struct {
+		name string
+		q    float64
+	}
+
+	var ranges []mediaRange
+	for _, accept := range r.Header["Accept"] {
+		for _, rng := range strings.Split(accept, ",") {
+			params := strings.Split(rng, ";")
+			name := strings.ToLower(strings.TrimSpace(params[0]))
+			if q := qvalue(params[1:]); name != "" && q > 0 {
+				ranges = append(ranges, mediaRange{name: name, q: q})
+			}
+		}
+	}
+
+	// more specific ranges win on equal q, such as "text/html" over "text/*" over "*/*"
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return mediaRangeSpecificity(ranges[i].name) > mediaRangeSpecificity(ranges[j].name)
+	})
+
+	names := make([]string, len(ranges))
+	for i, rng := range ranges {
+		names[i] = rng.name
+	}
+
+	return names
+}
+
+// mediaRangeSpecificity : returns 2 for a media type, 1 for "type/*" and 0 for "*/*"
+func mediaRangeSpecificity(rng string) int {
+	switch {
+	case rng == "*/*":
+		return 0
+	case strings.HasSuffix(rng, "/*"):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// matchMediaRange : returns true if given media type such as "application/json; charset=utf-8"
+// matches given media range such as "application/*"
+func matchMediaRange(rng, mediaType string) bool {
+	mediaType = strings.ToLower(strings.TrimSpace(strings.Split(mediaType, ";")[0]))
+
+	switch {
+	case rng == "*/*":
+		return true
+	case strings.HasSuffix(rng, "/*"):
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(rng, "*"))
+	default:
+		return rng == mediaType
+	}
+}
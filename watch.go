@@ -0,0 +1,70 @@
+package httpmocker
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// WatchFile : reloads mock responses from given YAML file every time the process receives SIGHUP.
+// If the file fails to load, mock server keeps serving the current responses.
+// Watching stops when mock server is closed.
+func (server *Server) WatchFile(path string) *Server {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+
+	done := make(chan struct{})
+	server.onClose(func() {
+		signal.Stop(sig)
+		close(done)
+	})
+
+	go func() {
+		for {
+			select {
+			case <-sig:
+				server.reloadFile(path)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return server
+}
+
+// reloadFile : replaces mock responses with the ones loaded from given YAML file.
+// Responses are swapped at once, and recorded requests and counters are kept.
+func (server *Server) reloadFile(path string) {
+	responses, err := loadYAML(path)
+	if err == nil {
+		var compiled map[string]map[string][]*Response
+		if compiled, err = compileResponses(responses); err == nil {
+			server.responsesMu.Lock()
+			server.Responses = compiled
+			server.responsesMu.Unlock()
+
+			server.logf("reloaded %d responses from %s", len(responses), path)
+			return
+		}
+	}
+
+	server.warnf("failed to reload %s, keeping current responses : %v", path, err)
+}
+
+// compileResponses : compiles given mock responses keyed by method and path, returns error instead of panic if any of them is invalid
+func compileResponses(responses []Response) (compiled map[string]map[string][]*Response, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			compiled, err = nil, fmt.Errorf("httpmocker: %v", r)
+		}
+	}()
+
+	compiled = map[string]map[string][]*Response{}
+	for _, response := range responses {
+		appendResponse(compiled, compileResponse(response))
+	}
+
+	return compiled, nil
+}
@@ -0,0 +1,98 @@
+//go:build !windows
+// +build !windows
+
+package httpmocker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWatchFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpmocker")
+	if err != nil {
+		t.Fatalf("unexpected error : %+v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "responses.yaml")
+	write := func(content string) {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+	}
+	write("- method: GET\n  path: /hello\n  code: 200\n  body: hello, world\n")
+
+	server, err := LaunchFromYAML(path)
+	if err != nil {
+		t.Fatalf("unexpected error : %+v", err)
+	}
+	defer server.Close()
+
+	logs := make(chanLogger, 16)
+	server.Logger = logs
+	server.WatchFile(path)
+
+	reload := func(expected string) {
+		if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case msg := <-logs:
+				if strings.HasPrefix(msg, "handler : ") {
+					continue
+				}
+				if !strings.HasPrefix(msg, expected) {
+					t.Errorf("log message should start with %q: actual %s", expected, msg)
+				}
+				return
+			case <-timeout:
+				t.Fatalf("responses were not reloaded")
+			}
+		}
+	}
+
+	get := func() string {
+		resp, err := http.Get(fmt.Sprintf("%s/hello", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		return string(body)
+	}
+
+	get()
+	write("- method: GET\n  path: /hello\n  code: 200\n  body: hello, reloaded\n")
+	reload("reloaded 1 responses")
+
+	if count := server.CallCount("GET", "/hello"); count != 1 {
+		t.Errorf("call count should be kept after reload: actual %d", count)
+	}
+
+	if body := get(); body != "hello, reloaded" {
+		t.Errorf("response body should be \"hello, reloaded\": actual %s", body)
+	}
+
+	write("- method: GET\n  path: /hello\n  unknown: field\n")
+	reload("failed to reload")
+
+	if body := get(); body != "hello, reloaded" {
+		t.Errorf("response body should be \"hello, reloaded\": actual %s", body)
+	}
+}
@@ -0,0 +1,92 @@
+package httpmocker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// yamlResponse : mock response definition in YAML
+type yamlResponse struct {
+	Method      string                `yaml:"method"`
+	Path        string                `yaml:"path"`
+	Query       string                `yaml:"query"`
+	Code        int                   `yaml:"code"`
+	ContentType string                `yaml:"contentType"`
+	Body        string                `yaml:"body"`
+	Headers     map[string]stringList `yaml:"headers"`
+}
+
+// stringList : list of strings which accepts a single string as well
+type stringList []string
+
+func (l *stringList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err == nil {
+		*l = stringList{s}
+		return nil
+	}
+
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return err
+	}
+	*l = list
+
+	return nil
+}
+
+func (r yamlResponse) response() Response {
+	var headers http.Header
+	if len(r.Headers) > 0 {
+		headers = http.Header{}
+		for k, values := range r.Headers {
+			for _, v := range values {
+				headers.Add(k, v)
+			}
+		}
+	}
+
+	return Response{
+		Method:      r.Method,
+		Path:        r.Path,
+		Query:       r.Query,
+		Code:        r.Code,
+		ContentType: r.ContentType,
+		Body:        r.Body,
+		Headers:     headers,
+	}
+}
+
+// loadYAML : reads mock responses from given YAML file
+func loadYAML(path string) ([]Response, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var defs []yamlResponse
+	if err := yaml.UnmarshalStrict(data, &defs); err != nil {
+		return nil, fmt.Errorf("httpmocker: failed to parse %s : %v", path, err)
+	}
+
+	responses := make([]Response, len(defs))
+	for i, def := range defs {
+		responses[i] = def.response()
+	}
+
+	return responses, nil
+}
+
+// LaunchFromYAML : launch mock server with mock responses defined in given YAML file.
+// The file must be a list of responses with method, path, query, code, contentType, body and headers.
+func LaunchFromYAML(path string) (*Server, error) {
+	responses, err := loadYAML(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Launch(responses...), nil
+}
@@ -0,0 +1,74 @@
+package httpmocker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLaunchFromYAML(t *testing.T) {
+	t.Run("load responses", func(t *testing.T) {
+		server, err := LaunchFromYAML("testdata/responses.yaml")
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		server.Logger = t
+		defer server.Close()
+
+		resp, err := http.Get(fmt.Sprintf("%s/hello", server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("status code should be 200 OK : actual %d", resp.StatusCode)
+		}
+
+		if ctype := resp.Header.Get("Content-Type"); ctype != "text/plain" {
+			t.Errorf("ContentType should be text/plain: actual %s", ctype)
+		}
+
+		if xh := resp.Header.Get("X-Custom-Header"); xh != "custom header from yaml" {
+			t.Errorf("X-Custom-Header should be \"custom header from yaml\": actual %s", xh)
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+
+		if string(body) != "hello, world" {
+			t.Errorf("response body should be \"hello, world\": actual %s", string(body))
+		}
+
+		resp, err = http.Post(fmt.Sprintf("%s/sushi", server.URL), "text/plain", nil)
+		if err != nil {
+			t.Fatalf("unexpected error : %+v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("status code should be 201 Created : actual %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		_, err := LaunchFromYAML("testdata/unknown_field.yaml")
+		if err == nil {
+			t.Fatalf("unknown field should be rejected")
+		}
+
+		if !strings.Contains(err.Error(), "status") {
+			t.Errorf("error should describe the unknown field : actual %s", err)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := LaunchFromYAML("testdata/missing.yaml"); err == nil {
+			t.Errorf("missing file should be an error")
+		}
+	})
+}